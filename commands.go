@@ -0,0 +1,557 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/bamzest/xiaomi-router-shell-enabler/pkg/config"
+	"github.com/bamzest/xiaomi-router-shell-enabler/pkg/logger"
+	"github.com/bamzest/xiaomi-router-shell-enabler/pkg/routers"
+	"github.com/bamzest/xiaomi-router-shell-enabler/pkg/utils"
+	"github.com/bamzest/xiaomi-router-shell-enabler/pkg/version"
+)
+
+// options 命令行参数，每个子命令只注册自己用到的部分
+type options struct {
+	host     string
+	password string
+	token    string // 已弃用，兼容旧版本
+	model    string
+	verbose  bool
+	output   string // 结果的输出格式，text 或 json
+	services string
+	force    bool
+
+	serialNumber string
+	snFile       string
+	snColumn     string
+	snFormat     string
+	snOut        string
+
+	authorizedKeyFiles stringSliceFlag
+	rootPassword       string
+	rootPasswordPrompt bool
+	persist            bool
+	unpersist          bool
+	recipeFile         string
+	mesh               bool
+	verifyReboot       bool
+	rebootTimeout      time.Duration
+
+	execCommand string
+	file        string // inspect-firmware 和 extract-salt 的文件
+
+	profile     string          // 使用的配置名称
+	saveProfile string          // 成功后保存为的配置名称
+	profileData *config.Profile // 已应用的配置，保存时沿用其中的密码来源
+}
+
+// 未指定 -services 或命令没有该参数时操作的服务
+const defaultServices = "ssh,telnet"
+
+// command 一个子命令
+type command struct {
+	name    string
+	args    string // 位置参数的说明
+	summary string
+	flags   func(fs *flag.FlagSet, o *options)
+	run     func(o *options, args []string) bool
+}
+
+// commands 所有子命令，按帮助信息中的顺序排列
+var commands = []*command{
+	{
+		name:    "enable",
+		summary: "启用SSH和Telnet",
+		flags: func(fs *flag.FlagSet, o *options) {
+			o.routerFlags(fs)
+			o.servicesFlag(fs, "要启用的服务")
+			o.forceFlag(fs)
+			o.recipeFlag(fs)
+			o.snFlag(fs)
+			fs.Var(&o.authorizedKeyFiles, "authorized-key", "写入dropbear的SSH公钥文件，可重复指定")
+			fs.StringVar(&o.rootPassword, "root-password", "", "将root密码设置为指定值，替代由序列号计算的密码")
+			fs.BoolVar(&o.rootPasswordPrompt, "root-password-prompt", false, "交互式输入新的root密码")
+			fs.BoolVar(&o.persist, "persist", false, "安装启动脚本，使SSH在重启和固件升级后保持启用")
+			fs.BoolVar(&o.mesh, "mesh", false, "同时在Mesh子节点上启用")
+			o.rebootFlags(fs)
+		},
+		run: routerCommand(opEnable),
+	},
+	{
+		name:    "disable",
+		summary: "关闭SSH和Telnet",
+		flags: func(fs *flag.FlagSet, o *options) {
+			o.routerFlags(fs)
+			o.servicesFlag(fs, "要关闭的服务")
+			o.forceFlag(fs)
+			o.recipeFlag(fs)
+			fs.BoolVar(&o.unpersist, "unpersist", false, "同时移除 persist 安装的启动脚本")
+		},
+		run: routerCommand(opDisable),
+	},
+	{
+		name:    "status",
+		summary: "检查SSH和Telnet的开启状态并显示登录凭据",
+		flags: func(fs *flag.FlagSet, o *options) {
+			o.routerFlags(fs)
+			o.servicesFlag(fs, "要检查的服务")
+			o.recipeFlag(fs)
+			o.snFlag(fs)
+			fs.BoolVar(&o.mesh, "mesh", false, "同时检查Mesh子节点")
+		},
+		run: routerCommand(opStatus),
+	},
+	{
+		name:    "exec",
+		args:    "<命令>",
+		summary: "在路由器上执行自定义命令",
+		flags: func(fs *flag.FlagSet, o *options) {
+			o.routerFlags(fs)
+			o.forceFlag(fs)
+		},
+		run: func(o *options, args []string) bool {
+			o.execCommand = strings.Join(args, " ")
+			if o.execCommand == "" {
+				fmt.Fprintln(os.Stderr, "错误: 缺少要执行的命令")
+				return false
+			}
+			return routerCommand(opExec)(o, nil)
+		},
+	},
+	{
+		name:    "resume",
+		summary: "继续执行上次中断的启用/关闭操作",
+		flags: func(fs *flag.FlagSet, o *options) {
+			o.routerFlags(fs)
+			o.forceFlag(fs)
+			o.recipeFlag(fs)
+		},
+		run: routerCommand(opResume),
+	},
+	{
+		name:    "persist",
+		summary: "安装启动脚本，使SSH在重启和固件升级后保持启用",
+		flags: func(fs *flag.FlagSet, o *options) {
+			o.routerFlags(fs)
+			o.servicesFlag(fs, "要保持启用的服务")
+			o.forceFlag(fs)
+			o.recipeFlag(fs)
+		},
+		run: routerCommand(opPersist),
+	},
+	{
+		name:    "unpersist",
+		summary: "移除 persist 安装的启动脚本",
+		flags: func(fs *flag.FlagSet, o *options) {
+			o.routerFlags(fs)
+			o.forceFlag(fs)
+			o.recipeFlag(fs)
+		},
+		run: routerCommand(opUnpersist),
+	},
+	{
+		name:    "reboot",
+		summary: "重启路由器并验证SSH和Telnet是否保持启用",
+		flags: func(fs *flag.FlagSet, o *options) {
+			o.routerFlags(fs)
+			o.servicesFlag(fs, "要验证的服务")
+			fs.DurationVar(&o.rebootTimeout, "reboot-timeout", 5*time.Minute, "等待路由器重启完成的最长时间")
+		},
+		run: routerCommand(opReboot),
+	},
+	{
+		name:    "mesh",
+		summary: "列出Mesh节点，配方设置了 mesh_relay 时检查子节点的状态",
+		flags: func(fs *flag.FlagSet, o *options) {
+			o.routerFlags(fs)
+			o.servicesFlag(fs, "要检查的服务")
+		},
+		run: routerCommand(opMesh),
+	},
+	{
+		name:    "probe",
+		summary: "用无害的标记命令探测路由器上可用的命令通道，不修改任何配置",
+		flags: func(fs *flag.FlagSet, o *options) {
+			o.routerFlags(fs)
+		},
+		run: runProbeCommand,
+	},
+	{
+		name:    "calc-password",
+		args:    "[序列号]",
+		summary: "由序列号计算SSH密码，或从CSV文件批量计算",
+		flags: func(fs *flag.FlagSet, o *options) {
+			fs.StringVar(&o.model, "model", "", "路由器型号，作为选择算法的提示")
+			fs.StringVar(&o.serialNumber, "sn", "", "路由器序列号，也可以作为位置参数")
+			o.profileFlag(fs)
+			o.snFileFlags(fs)
+		},
+		run: runCalcPassword,
+	},
+	{
+		name:    "models",
+		summary: "列出所有支持的路由器型号",
+		run: func(o *options, args []string) bool {
+			printModels()
+			return true
+		},
+	},
+	{
+		name:    "inspect-firmware",
+		args:    "<固件文件>",
+		summary: "解析本地的固件镜像，显示分段、版本和dropbear的release限制",
+		run: func(o *options, args []string) bool {
+			if len(args) != 1 {
+				fmt.Fprintln(os.Stderr, "错误: 需要指定一个固件文件")
+				return false
+			}
+			return runInspectFirmware(args[0])
+		},
+	},
+	{
+		name:    "extract-salt",
+		args:    "<mkxqimage或固件文件>",
+		summary: "从本地的 mkxqimage 程序或固件镜像中查找计算SSH密码使用的盐",
+		run: func(o *options, args []string) bool {
+			if len(args) != 1 {
+				fmt.Fprintln(os.Stderr, "错误: 需要指定一个文件")
+				return false
+			}
+			return runExtractSalt(args[0])
+		},
+	},
+	{
+		name:    "profiles",
+		summary: "列出配置文件中的路由器配置",
+		run: func(o *options, args []string) bool {
+			return printProfiles()
+		},
+	},
+	{
+		name:    "version",
+		summary: "显示版本信息",
+		run: func(o *options, args []string) bool {
+			printVersion()
+			return true
+		},
+	},
+}
+
+// findCommand 按名称查找子命令
+func findCommand(name string) *command {
+	for _, cmd := range commands {
+		if cmd.name == name {
+			return cmd
+		}
+	}
+	return nil
+}
+
+// flagSet 创建子命令的参数集，所有子命令都支持 -verbose 和 -output
+func (cmd *command) flagSet(o *options) *flag.FlagSet {
+	fs := flag.NewFlagSet(cmd.name, flag.ExitOnError)
+	o.commonFlags(fs)
+	if cmd.flags != nil {
+		cmd.flags(fs, o)
+	}
+	fs.Usage = func() {
+		fmt.Fprintf(os.Stderr, "%s\n\n", cmd.summary)
+		fmt.Fprintf(os.Stderr, "%s\n\n", strings.TrimSpace(fmt.Sprintf("用法: %s %s [选项] %s", os.Args[0], cmd.name, cmd.args)))
+		fmt.Fprintf(os.Stderr, "选项:\n")
+		fs.PrintDefaults()
+	}
+	return fs
+}
+
+// commonFlags 所有命令共用的参数
+func (o *options) commonFlags(fs *flag.FlagSet) {
+	fs.BoolVar(&o.verbose, "verbose", false, "显示详细日志")
+	fs.StringVar(&o.output, "output", outputText, "结果的输出格式，可选 text,json，日志始终输出到标准错误")
+}
+
+// routerFlags 连接路由器的参数
+func (o *options) routerFlags(fs *flag.FlagSet) {
+	fs.StringVar(&o.host, "host", "", "路由器IP地址")
+	fs.StringVar(&o.password, "password", "", "路由器管理密码")
+	fs.StringVar(&o.model, "model", "", "路由器型号，省略时自动识别")
+	fs.StringVar(&o.token, "token", "", "[已弃用] 路由器登录Token (请使用 -password 参数)")
+	o.profileFlag(fs)
+	fs.StringVar(&o.saveProfile, "save-profile", "", "操作成功后把本次的路由器参数保存为指定名称的配置")
+}
+
+func (o *options) profileFlag(fs *flag.FlagSet) {
+	fs.StringVar(&o.profile, "profile", "", "使用配置文件中指定名称的路由器配置，命令行参数优先")
+}
+
+func (o *options) servicesFlag(fs *flag.FlagSet, usage string) {
+	fs.StringVar(&o.services, "services", defaultServices, usage+"，逗号分隔，可选 ssh,telnet")
+}
+
+func (o *options) forceFlag(fs *flag.FlagSet) {
+	fs.BoolVar(&o.force, "force", false, "在已知被修补的固件上仍然执行")
+}
+
+func (o *options) recipeFlag(fs *flag.FlagSet) {
+	fs.StringVar(&o.recipeFile, "recipe", "", "使用自定义的启用/关闭配方文件 (YAML) 替代内置配方")
+}
+
+func (o *options) snFlag(fs *flag.FlagSet) {
+	fs.StringVar(&o.serialNumber, "sn", "", "路由器序列号，用于核对路由器报告的序列号")
+}
+
+func (o *options) rebootFlags(fs *flag.FlagSet) {
+	fs.BoolVar(&o.verifyReboot, "verify-reboot", false, "完成后重启路由器并验证是否保持启用")
+	fs.DurationVar(&o.rebootTimeout, "reboot-timeout", 5*time.Minute, "等待路由器重启完成的最长时间")
+}
+
+func (o *options) snFileFlags(fs *flag.FlagSet) {
+	fs.StringVar(&o.snFile, "sn-file", "", "从CSV文件批量读取序列号并计算SSH密码")
+	fs.StringVar(&o.snColumn, "sn-column", "", "序列号所在列的列名或列号 (从1开始)，省略时自动识别")
+	fs.StringVar(&o.snFormat, "sn-format", "csv", "批量计算结果的格式，可选 csv,json")
+	fs.StringVar(&o.snOut, "sn-out", "", "批量计算结果的输出文件，省略时输出到标准输出")
+}
+
+// runCalcPassword 计算一个序列号的SSH密码，或从CSV文件批量计算
+func runCalcPassword(o *options, args []string) bool {
+	if o.snFile != "" {
+		return runSNFile(o.snFile, o.snColumn, o.snFormat, o.snOut)
+	}
+
+	sn := o.serialNumber
+	if len(args) > 0 {
+		sn = args[0]
+	}
+	if sn == "" {
+		fmt.Fprintln(os.Stderr, "错误: 需要指定序列号或 -sn-file")
+		return false
+	}
+
+	// 型号只作为选择算法的提示，使用注册的型号ID以便与算法的型号列表对应
+	modelHint := o.model
+	if desc, ok := routers.LookupModel(modelHint); ok {
+		modelHint = desc.ID
+	}
+	out.Set("sn", sn)
+	candidates, err := utils.CandidatePasswords(sn, modelHint)
+	if len(candidates) == 0 {
+		logger.Error("无法计算SSH密码: %v", err)
+		return false
+	}
+	out.Set("passwords", utils.PasswordResults(candidates))
+	out.Printf("序列号: %s\n", sn)
+	printPasswordCandidates(candidates, "计算得到的SSH密码")
+	// 型号与序列号矛盾时仍列出各算法的结果以便核对，但不能当作成功
+	if err != nil {
+		logger.Error("无法确定SSH密码: %v", err)
+		return false
+	}
+	return true
+}
+
+// printVersion 显示版本信息
+func printVersion() {
+	out.Set("version", version.Version)
+	out.Set("build_time", version.BuildTime)
+	out.Set("git_commit", version.GitCommit)
+	out.Printf("Xiaomi Router Shell Enabler v%s\n", version.Version)
+	out.Printf("构建时间: %s\n", version.BuildTime)
+	out.Printf("提交哈希: %s\n", version.GitCommit)
+}
+
+// printModels 显示支持的型号及其别名、登录方式、命令通道、固件兼容性和支持的功能
+func printModels() {
+	models := make([]*modelResult, 0, len(routers.Models()))
+	for _, m := range routers.Models() {
+		models = append(models, newModelResult(m, true))
+	}
+	out.Set("models", models)
+
+	out.Println("支持的路由器型号:")
+	for _, m := range routers.Models() {
+		out.Printf("- %s (%s)\n", m.ID, m.DisplayName)
+		if len(m.Aliases) > 0 {
+			out.Printf("    别名: %s\n", strings.Join(m.Aliases, ", "))
+		}
+		out.Printf("    登录哈希: %s\n", m.HashMode)
+		out.Printf("    命令通道: %s\n", strings.Join(m.Channels, ", "))
+		if len(m.Firmware) == 0 {
+			out.Printf("    固件版本: 暂无兼容性记录\n")
+		}
+		for _, r := range m.Firmware {
+			out.Printf("    固件 %s: %s", r, r.Status.DisplayName())
+			if r.Note != "" {
+				out.Printf(" (%s)", r.Note)
+			}
+			out.Println()
+		}
+		capabilities := make([]string, 0, len(m.Capabilities))
+		for _, c := range m.Capabilities {
+			capabilities = append(capabilities, string(c))
+		}
+		out.Printf("    支持功能: %s\n", strings.Join(capabilities, ", "))
+	}
+}
+
+// applyProfile 用配置中的值填充命令行没有指定的参数，只填充当前命令支持的参数
+func (o *options) applyProfile(fs *flag.FlagSet) error {
+	if o.profile == "" {
+		return nil
+	}
+	cfg, err := config.Load()
+	if err != nil {
+		return err
+	}
+	p, err := cfg.Profile(o.profile)
+	if err != nil {
+		return err
+	}
+	logger.Debug("使用配置 %s (%s)", o.profile, cfg.File())
+
+	set := map[string]bool{}
+	fs.Visit(func(f *flag.Flag) { set[f.Name] = true })
+	fill := func(name string, ok bool, apply func()) {
+		if ok && fs.Lookup(name) != nil && !set[name] {
+			apply()
+		}
+	}
+
+	fill("host", true, func() { o.host = p.Host })
+	fill("model", p.Model != "", func() { o.model = p.Model })
+	fill("sn", p.SN != "", func() { o.serialNumber = p.SN })
+	fill("services", len(p.Services) > 0, func() { o.services = strings.Join(p.Services, ",") })
+	fill("authorized-key", len(p.AuthorizedKeys) > 0, func() { o.authorizedKeyFiles = p.AuthorizedKeys })
+
+	// 命令行指定了 -password 或 -token 时不读取配置中的密码
+	if p.HasPassword() && fs.Lookup("password") != nil && !set["password"] && !set["token"] {
+		password, err := p.ResolvePassword()
+		if err != nil {
+			return fmt.Errorf("读取配置 %s 的管理密码失败: %v", o.profile, err)
+		}
+		o.password = password
+	}
+
+	o.profileData = p
+	return nil
+}
+
+// saveProfile 把本次成功运行使用的路由器参数保存为配置
+// 命令行中的明文密码不会被保存，只沿用所用配置或同名配置中的密码来源
+func saveProfile(o *options, model, sn string) bool {
+	cfg, err := config.Load()
+	if err != nil {
+		logger.Error("保存配置失败: %v", err)
+		return false
+	}
+
+	p := &config.Profile{Host: o.host, Model: model, SN: sn}
+	if services, err := routers.ParseServices(o.services); err == nil {
+		for _, service := range services {
+			p.Services = append(p.Services, string(service))
+		}
+	}
+	for _, key := range o.authorizedKeyFiles {
+		path, err := filepath.Abs(utils.ExpandHome(key))
+		if err != nil {
+			path = key
+		}
+		p.AuthorizedKeys = append(p.AuthorizedKeys, path)
+	}
+
+	source := o.profileData
+	if existing, ok := cfg.Profiles[o.saveProfile]; ok && (source == nil || !source.HasPassword()) {
+		source = existing
+	}
+	if source != nil {
+		p.PasswordEnv, p.PasswordFile, p.Password = source.PasswordEnv, source.PasswordFile, source.Password
+	}
+
+	cfg.Profiles[o.saveProfile] = p
+	if err := cfg.Save(); err != nil {
+		logger.Error("保存配置失败: %v", err)
+		return false
+	}
+	out.Set("saved_profile", o.saveProfile)
+	logger.Info("已将本次的路由器参数保存为配置 %s (%s)", o.saveProfile, cfg.File())
+	if !p.HasPassword() {
+		logger.Info("配置中没有保存管理密码，可以在配置文件中为其设置 password_env 或 password_file")
+	}
+	return true
+}
+
+// printProfiles 显示配置文件中的路由器配置
+func printProfiles() bool {
+	cfg, err := config.Load()
+	if err != nil {
+		logger.Error("%v", err)
+		return false
+	}
+
+	out.Set("config_file", cfg.File())
+	profiles := make([]profileResult, 0, len(cfg.Profiles))
+	for _, name := range cfg.Names() {
+		profiles = append(profiles, newProfileResult(name, cfg.Profiles[name]))
+	}
+	out.Set("profiles", profiles)
+
+	out.Printf("配置文件: %s\n", cfg.File())
+	if len(cfg.Profiles) == 0 {
+		out.Println("还没有任何配置，可以在操作时使用 -save-profile 保存")
+		return true
+	}
+	for _, name := range cfg.Names() {
+		p := cfg.Profiles[name]
+		out.Printf("- %s: %s", name, p.Host)
+		if p.Model != "" {
+			out.Printf(" (%s)", p.Model)
+		}
+		out.Println()
+		switch {
+		case p.PasswordEnv != "":
+			out.Printf("    管理密码: 环境变量 %s\n", p.PasswordEnv)
+		case p.PasswordFile != "":
+			out.Printf("    管理密码: 文件 %s\n", p.PasswordFile)
+		case p.Password != "":
+			out.Printf("    管理密码: 明文保存在配置文件中\n")
+		}
+		if p.SN != "" {
+			out.Printf("    序列号: %s\n", p.SN)
+		}
+		if len(p.Services) > 0 {
+			out.Printf("    服务: %s\n", strings.Join(p.Services, ", "))
+		}
+		for _, key := range p.AuthorizedKeys {
+			out.Printf("    公钥: %s\n", key)
+		}
+	}
+	return true
+}
+
+// profileResult JSON输出中的一个配置，不包含明文密码
+type profileResult struct {
+	Name           string   `json:"name"`
+	Host           string   `json:"host"`
+	Model          string   `json:"model,omitempty"`
+	PasswordSource string   `json:"password_source,omitempty"` // env、file 或 plaintext
+	SN             string   `json:"sn,omitempty"`
+	Services       []string `json:"services,omitempty"`
+	AuthorizedKeys []string `json:"authorized_keys,omitempty"`
+}
+
+// newProfileResult 转换配置，只说明管理密码的来源
+func newProfileResult(name string, p *config.Profile) profileResult {
+	r := profileResult{Name: name, Host: p.Host, Model: p.Model, SN: p.SN, Services: p.Services, AuthorizedKeys: p.AuthorizedKeys}
+	switch {
+	case p.PasswordEnv != "":
+		r.PasswordSource = "env"
+	case p.PasswordFile != "":
+		r.PasswordSource = "file"
+	case p.Password != "":
+		r.PasswordSource = "plaintext"
+	}
+	return r
+}
@@ -2,7 +2,12 @@ module github.com/bamzest/xiaomi-router-shell-enabler
 
 go 1.20
 
-require github.com/fatih/color v1.18.0
+require (
+	github.com/fatih/color v1.18.0
+	golang.org/x/crypto v0.27.0
+	golang.org/x/term v0.24.0
+	gopkg.in/yaml.v3 v3.0.1
+)
 
 require (
 	github.com/mattn/go-colorable v0.1.13 // indirect
@@ -1,208 +1,747 @@
 package main
 
 import (
+	"context"
+	"errors"
 	"flag"
 	"fmt"
 	"os"
 	"strings"
+	"time"
 
+	"github.com/bamzest/xiaomi-router-shell-enabler/pkg/auth"
 	"github.com/bamzest/xiaomi-router-shell-enabler/pkg/client"
+	"github.com/bamzest/xiaomi-router-shell-enabler/pkg/firmware"
 	"github.com/bamzest/xiaomi-router-shell-enabler/pkg/logger"
+	"github.com/bamzest/xiaomi-router-shell-enabler/pkg/routers"
 	"github.com/bamzest/xiaomi-router-shell-enabler/pkg/utils"
 	"github.com/bamzest/xiaomi-router-shell-enabler/pkg/version"
+	"golang.org/x/term"
 )
 
 func main() {
-	// 定义命令行参数
-	host := flag.String("host", "", "路由器IP地址")
-	password := flag.String("password", "", "路由器管理密码") // 修改为密码参数
-	model := flag.String("model", "", "路由器型号")
-	listModels := flag.Bool("list", false, "列出所有支持的路由器型号")
-	verbose := flag.Bool("verbose", false, "显示详细日志")
-	showVersion := flag.Bool("version", false, "显示版本信息")
-	serialNumber := flag.String("sn", "", "路由器序列号，用于计算SSH密码")
-	calcPasswordOnly := flag.Bool("calc-password", false, "仅计算并显示SSH密码")
-	execCommand := flag.String("exec", "", "执行自定义命令")
-	enableShell := flag.Bool("enable_shell", false, "启用SSH和Telnet")
-	disableShell := flag.Bool("disable_shell", false, "关闭SSH和Telnet")
-	shellStatus := flag.Bool("shell_status", false, "检查SSH和Telnet的开启状态")
-	
-	// 兼容旧版本的 token 参数
-	token := flag.String("token", "", "[已弃用] 路由器登录Token (请使用 -password 参数)")
-	
-	// 自定义帮助信息
-	flag.Usage = func() {
-		fmt.Fprintf(os.Stderr, "Xiaomi Router Shell Enabler v%s\n\n", version.Version)
-		fmt.Fprintf(os.Stderr, "用法: %s [选项]\n\n", os.Args[0])
-		fmt.Fprintf(os.Stderr, "选项:\n")
-		flag.PrintDefaults()
-		fmt.Fprintf(os.Stderr, "\n示例:\n")
-		fmt.Fprintf(os.Stderr, "  %s -model redmi_ax5400pro -host 192.168.31.1 -password YOUR_PASSWORD -enable_shell -verbose\n", os.Args[0])
-		fmt.Fprintf(os.Stderr, "  %s -model redmi_ax5400pro -host 192.168.31.1 -password YOUR_PASSWORD -disable_shell -verbose\n", os.Args[0])
-		fmt.Fprintf(os.Stderr, "  %s -model redmi_ax5400pro -host 192.168.31.1 -password YOUR_PASSWORD -shell_status -verbose\n", os.Args[0])
-		fmt.Fprintf(os.Stderr, "  %s -sn 39668/A1ZZ38217 -calc-password\n", os.Args[0])
-		fmt.Fprintf(os.Stderr, "  %s -model redmi_ax5400pro -host 192.168.31.1 -password YOUR_PASSWORD -exec \"cat /etc/passwd\" -verbose\n", os.Args[0])
-		fmt.Fprintf(os.Stderr, "  %s -list\n", os.Args[0])
-		fmt.Fprintf(os.Stderr, "  %s -version\n", os.Args[0])
-	}
-	
-	flag.Parse()
-
-	// 显示版本信息
-	if *showVersion {
-		fmt.Printf("Xiaomi Router Shell Enabler v%s\n", version.Version)
-		fmt.Printf("构建时间: %s\n", version.BuildTime)
-		fmt.Printf("提交哈希: %s\n", version.GitCommit)
+	args := os.Args[1:]
+
+	// 旧版本的用法以参数开头，按已弃用的参数处理
+	if len(args) == 0 || strings.HasPrefix(args[0], "-") {
+		ok := runLegacy(args)
+		out.Flush(ok)
+		if !ok {
+			os.Exit(1)
+		}
 		return
 	}
 
-	// 配置日志级别
-	if *verbose {
+	name := args[0]
+	if name == "help" {
+		if len(args) < 2 {
+			usage()
+			return
+		}
+		if cmd := findCommand(args[1]); cmd != nil {
+			cmd.flagSet(&options{}).Usage()
+			return
+		}
+		name = args[1]
+	}
+
+	cmd := findCommand(name)
+	if cmd == nil {
+		fmt.Fprintf(os.Stderr, "未知的命令: %s\n\n", name)
+		usage()
+		os.Exit(2)
+	}
+
+	o := &options{}
+	fs := cmd.flagSet(o)
+	fs.Parse(args[1:])
+	if !setupOutput(o, cmd.name) {
+		os.Exit(2)
+	}
+	if err := o.applyProfile(fs); err != nil {
+		logger.Error("%v", err)
+		out.Flush(false)
+		os.Exit(1)
+	}
+
+	ok := cmd.run(o, fs.Args())
+	out.Flush(ok)
+	if !ok {
+		os.Exit(1)
+	}
+}
+
+// setupOutput 配置日志级别和结果的输出格式
+func setupOutput(o *options, command string) bool {
+	setupLogger(o.verbose)
+	if err := out.setFormat(o.output); err != nil {
+		fmt.Fprintf(os.Stderr, "错误: %v\n", err)
+		return false
+	}
+	out.command = command
+	return true
+}
+
+// setupLogger 配置日志级别
+func setupLogger(verbose bool) {
+	if verbose {
 		logger.SetLevel(logger.LevelDebug)
 		logger.Debug("调试模式已启用")
 	} else {
 		logger.SetLevel(logger.LevelInfo)
 	}
+}
+
+// usage 显示所有子命令
+func usage() {
+	fmt.Fprintf(os.Stderr, "Xiaomi Router Shell Enabler v%s\n\n", version.Version)
+	fmt.Fprintf(os.Stderr, "用法: %s <命令> [选项]\n\n", os.Args[0])
+	fmt.Fprintf(os.Stderr, "命令:\n")
+	for _, cmd := range commands {
+		fmt.Fprintf(os.Stderr, "  %-18s %s\n", cmd.name, cmd.summary)
+	}
+	fmt.Fprintf(os.Stderr, "\n使用 \"%s <命令> -h\" 查看命令的选项\n", os.Args[0])
+	fmt.Fprintf(os.Stderr, "\n示例:\n")
+	fmt.Fprintf(os.Stderr, "  %s enable -host 192.168.31.1 -password YOUR_PASSWORD\n", os.Args[0])
+	fmt.Fprintf(os.Stderr, "  %s enable -host 192.168.31.1 -password YOUR_PASSWORD -services ssh -authorized-key ~/.ssh/id_ed25519.pub\n", os.Args[0])
+	fmt.Fprintf(os.Stderr, "  %s enable -host 192.168.31.1 -password YOUR_PASSWORD -persist -verify-reboot\n", os.Args[0])
+	fmt.Fprintf(os.Stderr, "  %s disable -host 192.168.31.1 -password YOUR_PASSWORD -unpersist\n", os.Args[0])
+	fmt.Fprintf(os.Stderr, "  %s status -host 192.168.31.1 -password YOUR_PASSWORD\n", os.Args[0])
+	fmt.Fprintf(os.Stderr, "  %s exec -host 192.168.31.1 -password YOUR_PASSWORD \"cat /etc/passwd\"\n", os.Args[0])
+	fmt.Fprintf(os.Stderr, "  %s calc-password 39668/A1ZZ38217\n", os.Args[0])
+	fmt.Fprintf(os.Stderr, "  %s calc-password -sn-file inventory.csv -sn-format json\n", os.Args[0])
+	fmt.Fprintf(os.Stderr, "  %s status -profile office-ap\n", os.Args[0])
+	fmt.Fprintf(os.Stderr, "  %s models\n", os.Args[0])
+	fmt.Fprintf(os.Stderr, "\n旧版本的 -enable_shell、-disable_shell、-shell_status、-exec、-list 等参数仍然可用，但已弃用\n")
+}
+
+// runLegacy 兼容旧版本的参数，按原来的优先级把操作参数转换为子命令
+func runLegacy(args []string) bool {
+	o := &options{}
+	fs := flag.NewFlagSet(os.Args[0], flag.ExitOnError)
+	fs.Usage = usage
+
+	// 子命令的参数
+	o.commonFlags(fs)
+	o.routerFlags(fs)
+	o.servicesFlag(fs, "要启用、关闭或检查的服务")
+	o.forceFlag(fs)
+	o.recipeFlag(fs)
+	o.snFileFlags(fs)
+	fs.StringVar(&o.serialNumber, "sn", "", "路由器序列号，用于计算SSH密码")
+	fs.Var(&o.authorizedKeyFiles, "authorized-key", "启用时写入dropbear的SSH公钥文件，可重复指定")
+	fs.StringVar(&o.rootPassword, "root-password", "", "启用时将root密码设置为指定值")
+	fs.BoolVar(&o.rootPasswordPrompt, "root-password-prompt", false, "启用时交互式输入新的root密码")
+	fs.BoolVar(&o.persist, "persist", false, "安装启动脚本")
+	fs.BoolVar(&o.unpersist, "unpersist", false, "移除启动脚本")
+	fs.BoolVar(&o.mesh, "mesh", false, "列出Mesh节点并检查或启用子节点")
+	fs.BoolVar(&o.verifyReboot, "verify-reboot", false, "重启路由器并验证")
+	fs.DurationVar(&o.rebootTimeout, "reboot-timeout", 5*time.Minute, "等待路由器重启完成的最长时间")
+
+	// 已弃用的操作参数
+	showVersion := fs.Bool("version", false, "[已弃用] 请使用 version 命令")
+	listModels := fs.Bool("list", false, "[已弃用] 请使用 models 命令")
+	calcPassword := fs.Bool("calc-password", false, "[已弃用] 请使用 calc-password 命令")
+	inspectFirmware := fs.String("inspect-firmware", "", "[已弃用] 请使用 inspect-firmware 命令")
+	extractSalt := fs.String("extract-salt", "", "[已弃用] 请使用 extract-salt 命令")
+	probe := fs.Bool("probe", false, "[已弃用] 请使用 probe 命令")
+	shellStatus := fs.Bool("shell_status", false, "[已弃用] 请使用 status 命令")
+	execCommand := fs.String("exec", "", "[已弃用] 请使用 exec 命令")
+	enableShell := fs.Bool("enable_shell", false, "[已弃用] 请使用 enable 命令")
+	disableShell := fs.Bool("disable_shell", false, "[已弃用] 请使用 disable 命令")
+	resume := fs.Bool("resume", false, "[已弃用] 请使用 resume 命令")
+
+	fs.Parse(args)
+	if fs.NArg() > 0 {
+		fmt.Fprintf(os.Stderr, "错误: 多余的参数: %s\n", strings.Join(fs.Args(), " "))
+		return false
+	}
+	if !setupOutput(o, "") {
+		os.Exit(2)
+	}
+	if err := o.applyProfile(fs); err != nil {
+		logger.Error("%v", err)
+		return false
+	}
+
+	var name, flagName string
+	var cmdArgs []string
+	switch {
+	case *showVersion:
+		name, flagName = "version", "-version"
+	case *listModels:
+		name, flagName = "models", "-list"
+	case o.snFile != "":
+		name, flagName = "calc-password", "-sn-file"
+	case *inspectFirmware != "":
+		name, flagName, cmdArgs = "inspect-firmware", "-inspect-firmware", []string{*inspectFirmware}
+	case *extractSalt != "":
+		name, flagName, cmdArgs = "extract-salt", "-extract-salt", []string{*extractSalt}
+	case *calcPassword || (o.serialNumber != "" && o.host == ""):
+		name, flagName = "calc-password", "-calc-password"
+	case *probe:
+		name, flagName = "probe", "-probe"
+	case *shellStatus:
+		name, flagName = "status", "-shell_status"
+	case *execCommand != "":
+		name, flagName, cmdArgs = "exec", "-exec", []string{*execCommand}
+	case *enableShell:
+		name, flagName = "enable", "-enable_shell"
+	case *disableShell:
+		name, flagName = "disable", "-disable_shell"
+	case *resume:
+		name, flagName = "resume", "-resume"
+	case o.mesh:
+		name, flagName = "mesh", "-mesh"
+	case o.verifyReboot:
+		name, flagName = "reboot", "-verify-reboot"
+	case o.persist:
+		name, flagName = "persist", "-persist"
+	case o.unpersist:
+		name, flagName = "unpersist", "-unpersist"
+	default:
+		usage()
+		return false
+	}
+
+	fmt.Fprintf(os.Stderr, "注意: %s 参数已弃用，请使用 \"%s %s\"\n", flagName, os.Args[0], name)
+	out.command = name
+	return findCommand(name).run(o, cmdArgs)
+}
+
+// runProbe 探测各命令通道能否在此固件上执行命令
+func runProbe(ctx context.Context, host, password, model string) bool {
+	// 优先使用路由器报告的登录方式，其次使用指定型号的登录方式
+	hashMode := auth.HashSHA256
+	if info, err := routers.ProbeRouterInfo(ctx, host); err == nil {
+		logger.Info("路由器: %s", info.Description())
+		out.Set("router", newRouterResult(info))
+		hashMode = info.HashMode()
+	} else if desc, ok := routers.LookupModel(model); ok {
+		hashMode = desc.HashMode
+	} else {
+		logger.Warn("无法读取路由器信息 (%v)，使用 %s 登录", err, hashMode)
+	}
 
-	// 显示支持的型号列表
-	if *listModels {
-		fmt.Println("支持的路由器型号:")
-		for _, m := range client.GetSupportedModels() {
-			fmt.Printf("- %s\n", m)
+	results, err := client.ProbeChannels(ctx, host, password, hashMode)
+	if err != nil {
+		logger.Error("%v", err)
+		return false
+	}
+
+	out.Println("\n命令通道探测结果:")
+	working := 0
+	channels := make([]probeResult, 0, len(results))
+	for _, r := range results {
+		out.Printf("  %s: %s\n", r.Channel, r.Summary())
+		channels = append(channels, probeResult{Channel: r.Channel, Accepted: r.Accepted, Executed: r.Executed, Error: errorString(r.Err)})
+		if r.Executed {
+			working++
 		}
-		return
 	}
+	out.Set("channels", channels)
+	if working == 0 {
+		out.Println("  没有可用的命令通道")
+		return false
+	}
+	return true
+}
+
+// listMeshNodes 列出Mesh节点并记录到输出中，第一个为主路由
+func listMeshNodes(ctx context.Context, routerClient client.RouterClient) ([]routers.MeshNode, []*meshNodeResult, bool) {
+	nodes, err := routerClient.ListMeshNodes(ctx)
+	if err != nil {
+		logger.Error("%v", err)
+		return nil, nil, false
+	}
+
+	out.Println("\nMesh节点:")
+	results := make([]*meshNodeResult, 0, len(nodes))
+	for _, node := range nodes {
+		out.Printf("  %s\n", node.Description())
+		results = append(results, &meshNodeResult{MeshNode: node})
+	}
+	out.Set("mesh", results)
+	if len(nodes) == 1 {
+		out.Println("  没有Mesh子节点")
+	}
+	return nodes, results, true
+}
+
+// runMesh 列出Mesh节点，并通过主路由在每个子节点上启用所选服务或检查其状态，最后按节点汇总
+func runMesh(ctx context.Context, routerClient client.RouterClient, services []routers.Service, enable bool) bool {
+	nodes, results, ok := listMeshNodes(ctx, routerClient)
+	if !ok || len(nodes) == 1 {
+		return ok
+	}
+
+	for i, node := range nodes[1:] {
+		if ctx.Err() != nil {
+			return false
+		}
+		result := results[i+1]
 
-	// 如果提供了序列号，计算SSH密码
-	if *serialNumber != "" {
-		sshPassword := utils.CalculateSSHPassword(*serialNumber)
-		if sshPassword != "" {
-			fmt.Printf("序列号: %s\n", *serialNumber)
-			fmt.Printf("计算得到的SSH密码: %s\n", sshPassword)
-			
-			// 如果只是计算密码，则退出
-			if *calcPasswordOnly {
-				return
-			}
+		var status *routers.ShellStatusResult
+		var details string
+		var err error
+		if enable {
+			status, details, err = routerClient.EnableMeshNode(ctx, node, services)
 		} else {
-			fmt.Println("无法计算SSH密码，请检查序列号是否正确。")
-			if *calcPasswordOnly {
-				os.Exit(1)
-			}
+			status, details, err = routerClient.CheckMeshNodeStatus(ctx, node, services)
+		}
+
+		out.Printf("\n%s:\n", node.Description())
+		if err != nil {
+			out.Printf("  失败: %v\n", err)
+			result.Error = err.Error()
+			ok = false
+			continue
+		}
+		result.Status = status
+		for _, service := range services {
+			out.Printf("  %s\n", status.Summary(service))
+		}
+		out.Println(details)
+		if enable && !status.AllReady(services) {
+			ok = false
 		}
 	}
+	return ok
+}
 
-	// 检查必需参数
-	if (*host == "" || (*password == "" && *token == "") || *model == "") && !*calcPasswordOnly {
-		if *serialNumber == "" || !*calcPasswordOnly {
-			fmt.Println("错误: 必须提供路由器IP地址、管理密码和型号")
-			fmt.Println("用法示例: xiaomi-router-shell-enabler -model redmi_ax5400pro -host 192.168.31.1 -password YOUR_PASSWORD -enable_shell")
-			fmt.Println("或使用 -h 查看帮助信息")
-			os.Exit(1)
+// resolveModel 读取路由器的 init_info 识别型号，并与用户指定的 -model 核对
+// 指定了 -model 时以用户的选择为准，检测结果不一致时给出警告；未指定时使用检测结果
+func resolveModel(ctx context.Context, host, model string) (*routers.ModelDescriptor, *routers.RouterInfo, error) {
+	info, probeErr := routers.ProbeRouterInfo(ctx, host)
+	if probeErr != nil {
+		logger.Debug("自动识别型号失败: %v", probeErr)
+	}
+
+	var detected *routers.ModelDescriptor
+	if info != nil {
+		detected, _ = routers.DetectModel(info)
+	}
+
+	if model == "" {
+		switch {
+		case probeErr != nil:
+			return nil, nil, fmt.Errorf("无法自动识别路由器型号 (%v)，请使用 -model 指定", probeErr)
+		case detected == nil:
+			return nil, info, fmt.Errorf("检测到的路由器 %s 不在支持列表中，请使用 -model 指定", info.Description())
 		}
+		logger.Info("自动识别路由器型号: %s", detected.ID)
+		return detected, info, nil
 	}
 
-	// 如果只是计算密码并且已经完成，则退出
-	if *calcPasswordOnly && *serialNumber != "" {
-		return
+	desc, ok := routers.LookupModel(model)
+	if !ok {
+		return nil, info, fmt.Errorf("不支持的路由器型号: %s", model)
 	}
 
-	// 验证主机地址格式
-	if !strings.HasPrefix(*host, "http://") && !strings.HasPrefix(*host, "https://") {
-		// 如果用户没有提供协议前缀，默认添加http://
-		*host = strings.TrimPrefix(*host, "http://")
-		*host = strings.TrimPrefix(*host, "https://")
-		// 移除可能的尾部斜杠
-		*host = strings.TrimSuffix(*host, "/")
-		logger.Debug("使用主机地址: %s", *host)
+	switch {
+	case info == nil:
+		logger.Warn("无法读取路由器信息，无法核对型号，按指定的 %s 继续", desc.ID)
+	case detected == nil:
+		logger.Warn("检测到的硬件代号 %s 不在支持列表中，按指定的 %s 继续", info.Hardware, desc.ID)
+	case detected != desc:
+		logger.Warn("指定的型号 %s 与检测到的型号 %s (硬件: %s) 不一致，按指定的 %s 继续，可能会失败", desc.ID, detected.ID, info.Hardware, desc.ID)
+	}
+	if info != nil && info.HashMode() != desc.HashMode {
+		logger.Info("路由器登录使用 %s，与 %s 的默认设置 %s 不同，按路由器报告的登录", info.HashMode(), desc.ID, desc.HashMode)
 	}
+	return desc, info, nil
+}
 
-	// 如果用户提供了 token 而不是 password，发出警告
-	routerPassword := *password
-	if routerPassword == "" && *token != "" {
-		logger.Warn("-token 参数已弃用，请使用 -password 参数")
-		routerPassword = *token
+// stringSliceFlag 可重复指定的字符串参数
+type stringSliceFlag []string
+
+func (s *stringSliceFlag) String() string {
+	return strings.Join(*s, ",")
+}
+
+func (s *stringSliceFlag) Set(value string) error {
+	*s = append(*s, value)
+	return nil
+}
+
+// installAuthorizedKeys 写入公钥并逐个验证公钥登录
+// 本地没有私钥的公钥（如他人的公钥）无法验证，只给出警告；有私钥却无法登录，或没有任何公钥通过验证时返回错误
+func installAuthorizedKeys(ctx context.Context, routerClient client.RouterClient, host string, keys []utils.AuthorizedKey) error {
+	lines := make([]string, 0, len(keys))
+	for _, key := range keys {
+		lines = append(lines, key.Line)
 	}
 
-	logger.Debug("连接信息: 主机=%s, 型号=%s", *host, *model)
+	logger.Info("写入 %d 个SSH公钥...", len(lines))
+	if err := routerClient.InstallAuthorizedKeys(ctx, lines); err != nil {
+		return fmt.Errorf("写入SSH公钥失败: %v", err)
+	}
 
-	// 创建路由器客户端
-	routerClient, err := client.NewRouterClient(*host, routerPassword, *model)
-	if err != nil {
+	logger.Info("验证公钥登录...")
+	results := make([]authorizedKeyResult, 0, len(keys))
+	defer func() { out.Set("authorized_keys", results) }()
+	verified, failed := 0, 0
+	for _, key := range keys {
+		result := authorizedKeyResult{Path: key.Path, Key: key.Line}
+		err := utils.VerifySSHKeyLogin(host, key)
+		switch {
+		case err == nil:
+			logger.Info("公钥 %s 登录验证成功", key.Path)
+			result.Verified = true
+			verified++
+		case errors.Is(err, utils.ErrNoPrivateKey):
+			logger.Warn("公钥 %s 无法验证: %v", key.Path, err)
+			result.Error = err.Error()
+		default:
+			logger.Error("公钥 %s 登录验证失败: %v", key.Path, err)
+			result.Error = err.Error()
+			failed++
+		}
+		results = append(results, result)
+	}
+
+	switch {
+	case failed > 0:
+		return fmt.Errorf("%d 个SSH公钥登录验证失败，公钥已写入但可能无法使用", failed)
+	case verified == 0:
+		return fmt.Errorf("没有任何SSH公钥通过登录验证，请至少提供一个本地有私钥（或已加入ssh-agent）的公钥")
+	}
+	return nil
+}
+
+// 退出前清理的最长时间，此时ctx可能已被取消，需要使用独立的ctx
+const cleanupTimeout = 15 * time.Second
+
+// cleanupClient 删除本次运行在路由器上创建的临时任务并注销登录，失败不影响退出
+func cleanupClient(routerClient client.RouterClient) {
+	ctx, cancel := context.WithTimeout(context.Background(), cleanupTimeout)
+	defer cancel()
+	if err := routerClient.Cleanup(ctx); err != nil {
+		logger.Debug("清理失败: %v", err)
+	}
+}
+
+// 管理页面恢复后，等待SSH等服务启动的时间
+const rebootSettleDelay = 20 * time.Second
+
+// rebootAndVerify 重启路由器，重新登录后逐个检查所选服务是否保持启用
+func rebootAndVerify(ctx context.Context, routerClient client.RouterClient, host, password, model string, hashMode auth.HashMode, services []routers.Service, timeout time.Duration) bool {
+	if err := routerClient.Reboot(ctx); err != nil {
 		logger.Error("%v", err)
-		fmt.Println("支持的型号: ", client.GetSupportedModels())
-		os.Exit(1)
+		return false
+	}
+	if err := routers.WaitForReboot(ctx, host, timeout); err != nil {
+		logger.Error("等待路由器重启失败: %v", err)
+		return false
 	}
 
-	// 处理不同的操作模式
-	if *shellStatus {
-		// 检查SSH和Telnet状态
-		logger.Info("检查 %s 路由器的SSH和Telnet状态...", *model)
-		status, details, err := routerClient.CheckShellStatus()
-		if err != nil {
-			logger.Error("检查状态失败: %v", err)
-			os.Exit(1)
-		}
-		
-		// 显示状态摘要
-		if status {
-			logger.Info("SSH和Telnet服务状态: 已启用并可访问")
+	logger.Info("等待路由器服务启动...")
+	select {
+	case <-ctx.Done():
+		logger.Error("等待路由器服务启动时被中断")
+		return false
+	case <-time.After(rebootSettleDelay):
+	}
+
+	// 重启后原来的stok已失效，需要重新登录
+	newClient, err := client.NewRouterClient(ctx, host, password, model, hashMode)
+	if err != nil {
+		logger.Error("重启后重新登录失败: %v", err)
+		return false
+	}
+	defer cleanupClient(newClient)
+
+	status, details, err := newClient.CheckShellStatus(ctx, services)
+	if err != nil {
+		logger.Error("重启后检查状态失败: %v", err)
+		return false
+	}
+
+	out.Set("reboot_status", status)
+	out.Println("\n重启验证结果:")
+	for _, service := range services {
+		if status.Ready(service) {
+			out.Printf("  %s: 重启后仍然可用\n", service.DisplayName())
 		} else {
-			logger.Warn("SSH和Telnet服务状态: 未完全启用或不可访问")
-		}
-		
-		// 显示详细状态信息
-		fmt.Println("\n详细状态信息:")
-		fmt.Println(details)
-		
-	} else if *execCommand != "" {
-		// 执行自定义命令模式
-		logger.Info("执行自定义命令: %s", *execCommand)
-		err := routerClient.ExecuteCustomCommand(*execCommand)
-		if err != nil {
-			logger.Error("执行命令失败: %v", err)
-			os.Exit(1)
+			out.Printf("  %s: 重启后未保持启用 (%s)\n", service.DisplayName(), status.Summary(service))
 		}
-		logger.Info("命令执行完成")
-	} else if *enableShell {
-		// 启用SSH和Telnet模式
-		logger.Info("开始为 %s 路由器启用SSH和Telnet...", *model)
-		err = routerClient.EnableSSH()
-		if err != nil {
-			logger.Error("启用SSH和Telnet失败: %v", err)
-			os.Exit(1)
+	}
+	if !status.AllReady(services) {
+		out.Println("  可以使用 persist 命令或 enable -persist 安装启动脚本")
+	}
+	out.Println("\n" + details)
+	return status.AllReady(services)
+}
+
+// containsSSH 判断所选服务中是否包含SSH
+func containsSSH(services []routers.Service) bool {
+	for _, service := range services {
+		if service == routers.ServiceSSH {
+			return true
 		}
-		
-		// 如果提供了序列号，显示SSH连接信息
-		if *serialNumber != "" {
-			sshPassword := utils.CalculateSSHPassword(*serialNumber)
-			if sshPassword != "" {
-				fmt.Printf("\n登录凭据:\n")
-				fmt.Printf("  用户名: root\n")
-				fmt.Printf("  密码: %s\n", sshPassword)
-				
-				// 显示SSH连接命令
-				fmt.Printf("\n连接命令:\n")
-				fmt.Printf("  SSH: %s\n", routerClient.GetSSHCommand())
-				fmt.Printf("  Telnet: %s\n", routerClient.GetTelnetCommand())
-			}
-		} else {
-			fmt.Printf("\n提示: 如果您知道路由器序列号，可以使用 -sn 参数计算SSH密码\n")
-			fmt.Printf("例如: %s -sn YOUR_SERIAL_NUMBER -calc-password\n", os.Args[0])
+	}
+	return false
+}
+
+// runSNFile 从CSV读取序列号，逐行计算SSH密码并输出，序列号无效的行会被标记而不中止
+// 使用 -output json 且没有指定输出文件时，结果包含在命令的JSON输出中
+func runSNFile(path, column, format, outPath string) bool {
+	if format != "csv" && format != "json" {
+		logger.Error("不支持的输出格式: %s (可选 csv,json)", format)
+		return false
+	}
+
+	in, err := os.Open(path)
+	if err != nil {
+		logger.Error("打开序列号文件失败: %v", err)
+		return false
+	}
+	defer in.Close()
+
+	inventory, err := utils.ReadInventory(in, column)
+	if err != nil {
+		logger.Error("读取序列号文件失败: %v", err)
+		return false
+	}
+
+	// 型号列只作为选择算法的提示
+	invalid := inventory.Calculate(func(model string) string {
+		if desc, ok := routers.LookupModel(model); ok {
+			return desc.ID
 		}
-	} else if *disableShell {
-		// 关闭SSH和Telnet模式
-		logger.Info("开始为 %s 路由器关闭SSH和Telnet...", *model)
-		err = routerClient.DisableSSH()
-		if err != nil {
-			logger.Error("关闭SSH和Telnet失败: %v", err)
-			os.Exit(1)
+		return model
+	})
+
+	out.Set("invalid", invalid)
+	if out.json && outPath == "" {
+		out.Set("rows", inventory.Results())
+		return true
+	}
+
+	w := os.Stdout
+	if outPath != "" {
+		if w, err = os.Create(outPath); err != nil {
+			logger.Error("创建输出文件失败: %v", err)
+			return false
+		}
+		defer w.Close()
+		out.Set("output_file", outPath)
+	}
+
+	if format == "json" {
+		err = inventory.WriteJSON(w)
+	} else {
+		err = inventory.WriteCSV(w)
+	}
+	if err != nil {
+		logger.Error("写入结果失败: %v", err)
+		return false
+	}
+
+	// 输出到标准输出时不打印摘要，以免混入结果
+	if outPath != "" {
+		logger.Info("已计算 %d 行，结果已写入 %s", len(inventory.Rows), outPath)
+		if invalid > 0 {
+			logger.Warn("%d 行的序列号无效，已在结果中标记", invalid)
 		}
-		logger.Info("SSH和Telnet关闭操作完成")
+	}
+	return true
+}
+
+// runInspectFirmware 解析固件镜像并显示其内容
+func runInspectFirmware(path string) bool {
+	result, err := firmware.InspectImage(path)
+	if err != nil {
+		logger.Error("解析固件失败: %v", err)
+		return false
+	}
+	img := result.Image
+	out.Set("image", img)
+
+	out.Printf("固件镜像: %s\n", path)
+	out.Printf("  设备ID: 0x%04x\n", img.DeviceID)
+	if img.CRCMatches() {
+		out.Printf("  CRC32: 0x%08x (一致)\n", img.CRC32)
 	} else {
-		// 如果没有指定具体操作，显示帮助信息
-		fmt.Println("请指定要执行的操作: -enable_shell, -disable_shell, -shell_status 或 -exec 命令")
-		fmt.Println("使用 -h 查看帮助信息")
-		os.Exit(1)
+		out.Printf("  CRC32: 0x%08x (与计算结果 0x%08x 不一致)\n", img.CRC32, img.ComputedCRC32)
+	}
+	if img.SignatureOffset != 0 {
+		out.Printf("  签名块: 偏移 0x%x，%d 字节\n", img.SignatureOffset, img.SignatureSize)
+	} else {
+		out.Printf("  签名块: 无\n")
+	}
+
+	out.Printf("\n分段:\n")
+	for i, blob := range img.Blobs {
+		out.Printf("  %d. %s (类型 %d, %s) 偏移 0x%x，%d 字节，刷写地址 0x%x\n",
+			i+1, blob.Name, blob.Type, blob.Kind, blob.Offset, blob.Size, blob.FlashAddr)
+		for _, vol := range blob.Volumes {
+			out.Printf("     UBI卷 %d %s (%s) %d 字节\n", vol.ID, vol.Name, vol.Kind(), len(vol.Data))
+		}
+	}
+
+	if result.RootfsErr != nil {
+		logger.Warn("无法读取固件的文件系统: %v", result.RootfsErr)
+		out.Set("rootfs_error", result.RootfsErr.Error())
+		return true
+	}
+	out.Set("rom_version", result.RomVersion)
+	out.Set("channel", result.Channel)
+	out.Set("hardware", result.Hardware)
+	out.Set("dropbear_found", result.DropbearFound)
+	out.Set("dropbear_gated", result.DropbearGated)
+
+	out.Printf("\n固件信息:\n")
+	out.Printf("  版本: %s\n", result.RomVersion)
+	out.Printf("  渠道: %s\n", result.Channel)
+	if desc, ok := routers.LookupHardware(result.Hardware); ok {
+		out.Printf("  硬件: %s (%s)\n", result.Hardware, desc.DisplayName)
+		check := desc.CheckFirmware(result.RomVersion)
+		out.Set("model", newModelResult(desc, false))
+		out.Set("firmware", check)
+		out.Printf("  兼容性: %s\n", check.Explain(desc))
+	} else {
+		out.Printf("  硬件: %s (不在支持的型号中)\n", result.Hardware)
+	}
+
+	switch {
+	case !result.DropbearFound:
+		out.Printf("  Dropbear: 固件中没有 /etc/init.d/dropbear\n")
+	case result.DropbearGated:
+		out.Printf("  Dropbear: 启动脚本包含 release 限制，启用SSH时需要修改\n")
+	default:
+		out.Printf("  Dropbear: 启动脚本没有 release 限制\n")
+	}
+	return true
+}
+
+// runExtractSalt 从 mkxqimage 或固件镜像中查找候选盐并显示
+func runExtractSalt(path string) bool {
+	candidates, err := firmware.ExtractSalts(path)
+	if err != nil {
+		logger.Error("查找盐失败: %v", err)
+		return false
+	}
+	if len(candidates) == 0 {
+		logger.Error("没有找到UUID形式的盐")
+		return false
+	}
+
+	out.Set("salts", candidates)
+	out.Printf("找到 %d 个候选盐:\n", len(candidates))
+	for i, c := range candidates {
+		out.Printf("  %d. %s\n", i+1, c.Description())
+	}
+	return true
+}
+
+// resolveSerialNumber 从路由器读取序列号并与用户指定的 -sn 核对
+// 路由器报告的序列号不会有输入错误，不一致时以路由器为准；无法读取时使用用户指定的序列号
+func resolveSerialNumber(ctx context.Context, routerClient client.RouterClient, userSN string) string {
+	userSN = strings.TrimSpace(userSN)
+
+	sn, err := routerClient.GetSerialNumber(ctx)
+	if err != nil {
+		if userSN != "" {
+			logger.Warn("无法从路由器读取序列号 (%v)，使用指定的序列号 %s", err, userSN)
+		} else {
+			logger.Warn("无法从路由器读取序列号: %v", err)
+		}
+		return userSN
+	}
+
+	logger.Info("路由器序列号: %s", sn)
+	if userSN != "" && !strings.EqualFold(userSN, sn) {
+		logger.Warn("指定的序列号 %s 与路由器报告的 %s 不一致，将使用路由器报告的序列号", userSN, sn)
+	}
+	return sn
+}
+
+// printCredentials 显示由序列号计算的root密码和连接命令
+func printCredentials(routerClient client.RouterClient, services []routers.Service, model, sn string) {
+	credentials := &credentialsResult{SN: sn, Username: "root"}
+	defer out.Set("credentials", credentials)
+
+	if sn == "" {
+		credentials.Error = "无法获取路由器序列号"
+		out.Printf("\n提示: 无法获取路由器序列号，可以使用 -sn 参数计算SSH密码\n")
+		out.Printf("例如: %s calc-password YOUR_SERIAL_NUMBER\n", os.Args[0])
+		return
+	}
+
+	candidates, err := utils.CandidatePasswords(sn, model)
+	if len(candidates) == 0 {
+		credentials.Error = err.Error()
+		out.Printf("\n无法计算SSH密码: %v\n", err)
+		return
+	}
+	credentials.Passwords = utils.PasswordResults(candidates)
+
+	out.Printf("\n登录凭据:\n")
+	out.Printf("  序列号: %s\n", sn)
+	out.Printf("  用户名: root\n")
+	printPasswordCandidates(candidates, "  密码")
+	if err != nil {
+		credentials.Error = err.Error()
+		logger.Warn("无法确定SSH密码: %v", err)
+	}
+	printConnectionCommands(routerClient, services, credentials)
+}
+
+// printPasswordCandidates 显示计算出的SSH密码，有多个候选时逐个列出并说明依据
+func printPasswordCandidates(candidates []utils.PasswordCandidate, label string) {
+	if len(candidates) == 1 {
+		out.Printf("%s: %s\n", label, candidates[0].Password)
+		return
+	}
+
+	out.Printf("%s: 有多个可能的密码，请依次尝试:\n", label)
+	for i, c := range candidates {
+		out.Printf("    %d. %s (算法 %s: %s)\n", i+1, c.Password, c.Algorithm.Name, c.Reason)
+	}
+}
+
+// printConnectionCommands 显示所选服务的连接命令，并记录到登录凭据中
+func printConnectionCommands(routerClient client.RouterClient, services []routers.Service, credentials *credentialsResult) {
+	out.Printf("\n连接命令:\n")
+	for _, service := range services {
+		switch service {
+		case routers.ServiceSSH:
+			credentials.SSHCommand = routerClient.GetSSHCommand()
+			out.Printf("  SSH: %s\n", credentials.SSHCommand)
+		case routers.ServiceTelnet:
+			credentials.TelnetCommand = routerClient.GetTelnetCommand()
+			out.Printf("  Telnet: %s\n", credentials.TelnetCommand)
+		}
+	}
+}
+
+// promptNewPassword 交互式读取新密码，需要输入两次确认
+func promptNewPassword() (string, error) {
+	fd := int(os.Stdin.Fd())
+	if !term.IsTerminal(fd) {
+		return "", fmt.Errorf("标准输入不是终端，无法交互式输入密码")
+	}
+
+	fmt.Fprint(os.Stderr, "请输入新的root密码: ")
+	first, err := term.ReadPassword(fd)
+	fmt.Fprintln(os.Stderr)
+	if err != nil {
+		return "", fmt.Errorf("读取密码失败: %v", err)
+	}
+
+	fmt.Fprint(os.Stderr, "请再次输入新的root密码: ")
+	second, err := term.ReadPassword(fd)
+	fmt.Fprintln(os.Stderr)
+	if err != nil {
+		return "", fmt.Errorf("读取密码失败: %v", err)
+	}
+
+	if string(first) != string(second) {
+		return "", fmt.Errorf("两次输入的密码不一致")
+	}
+	if len(first) == 0 {
+		return "", fmt.Errorf("root密码不能为空")
 	}
-}
\ No newline at end of file
+	return string(first), nil
+}
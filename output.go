@@ -0,0 +1,179 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+
+	"github.com/bamzest/xiaomi-router-shell-enabler/pkg/logger"
+	"github.com/bamzest/xiaomi-router-shell-enabler/pkg/routers"
+	"github.com/bamzest/xiaomi-router-shell-enabler/pkg/utils"
+)
+
+// -output 可选的输出格式
+const (
+	outputText = "text"
+	outputJSON = "json"
+)
+
+// report 命令的结果
+// 文本模式下直接打印到标准输出；JSON模式下只收集结构化的结果，命令结束时以一个JSON对象输出
+// 日志始终输出到标准错误，不会混入结果
+type report struct {
+	json    bool
+	command string
+	fields  map[string]interface{}
+	errors  []string
+}
+
+// out 当前命令的结果
+var out = &report{fields: map[string]interface{}{}}
+
+// setFormat 设置输出格式，JSON模式下同时收集错误日志
+func (r *report) setFormat(format string) error {
+	switch format {
+	case "", outputText:
+		r.json = false
+	case outputJSON:
+		r.json = true
+		logger.SetErrorHook(func(message string) {
+			r.errors = append(r.errors, message)
+		})
+	default:
+		return fmt.Errorf("不支持的输出格式: %s (可选 text,json)", format)
+	}
+	return nil
+}
+
+// Printf 以文本模式输出，JSON模式下忽略
+func (r *report) Printf(format string, args ...interface{}) {
+	if !r.json {
+		fmt.Printf(format, args...)
+	}
+}
+
+// Println 以文本模式输出，JSON模式下忽略
+func (r *report) Println(args ...interface{}) {
+	if !r.json {
+		fmt.Println(args...)
+	}
+}
+
+// Set 记录一项结构化结果，文本模式下忽略
+func (r *report) Set(key string, value interface{}) {
+	if r.json {
+		r.fields[key] = value
+	}
+}
+
+// Flush JSON模式下输出收集的结果，ok 为命令是否成功
+func (r *report) Flush(ok bool) {
+	if !r.json {
+		return
+	}
+
+	result := map[string]interface{}{}
+	for key, value := range r.fields {
+		result[key] = value
+	}
+	result["command"] = r.command
+	result["ok"] = ok
+	result["errors"] = append([]string{}, r.errors...)
+
+	encoder := json.NewEncoder(os.Stdout)
+	encoder.SetIndent("", "  ")
+	encoder.SetEscapeHTML(false)
+	if err := encoder.Encode(result); err != nil {
+		fmt.Fprintf(os.Stderr, "错误: 输出结果失败: %v\n", err)
+	}
+}
+
+// routerResult JSON输出中路由器报告的信息
+type routerResult struct {
+	Hardware    string `json:"hardware"`
+	RomVersion  string `json:"rom_version"`
+	Model       string `json:"model"`
+	DisplayName string `json:"display_name"`
+	RouterName  string `json:"router_name,omitempty"`
+	CountryCode string `json:"country_code,omitempty"`
+	HashMode    string `json:"hash_mode"`
+}
+
+// newRouterResult 转换路由器报告的信息
+func newRouterResult(info *routers.RouterInfo) *routerResult {
+	return &routerResult{
+		Hardware:    info.Hardware,
+		RomVersion:  info.RomVersion,
+		Model:       info.Model,
+		DisplayName: info.DisplayName,
+		RouterName:  info.RouterName,
+		CountryCode: info.CountryCode,
+		HashMode:    string(info.HashMode()),
+	}
+}
+
+// modelResult JSON输出中的型号
+type modelResult struct {
+	ID           string                  `json:"id"`
+	DisplayName  string                  `json:"display_name"`
+	Aliases      []string                `json:"aliases,omitempty"`
+	Hardware     []string                `json:"hardware,omitempty"`
+	HashMode     string                  `json:"hash_mode,omitempty"`
+	Channels     []string                `json:"channels,omitempty"`
+	Firmware     []routers.FirmwareRange `json:"firmware,omitempty"`
+	Capabilities []routers.Capability    `json:"capabilities,omitempty"`
+}
+
+// newModelResult 型号的简要信息，full 为 true 时包含登录方式、命令通道、固件兼容性和支持的功能
+func newModelResult(d *routers.ModelDescriptor, full bool) *modelResult {
+	m := &modelResult{ID: d.ID, DisplayName: d.DisplayName}
+	if full {
+		m.Aliases, m.Hardware = d.Aliases, d.Hardware
+		m.HashMode = string(d.HashMode)
+		m.Channels, m.Firmware, m.Capabilities = d.Channels, d.Firmware, d.Capabilities
+	}
+	return m
+}
+
+// credentialsResult JSON输出中的登录凭据
+type credentialsResult struct {
+	SN             string                 `json:"sn,omitempty"`
+	Username       string                 `json:"username"`
+	Passwords      []utils.PasswordResult `json:"passwords,omitempty"`
+	CustomPassword bool                   `json:"custom_password,omitempty"` // 已设置为自定义密码，不输出密码本身
+	Verified       bool                   `json:"verified,omitempty"`        // 自定义密码已通过SSH登录验证
+	Error          string                 `json:"error,omitempty"`           // 无法计算密码的原因
+	SSHCommand     string                 `json:"ssh_command,omitempty"`
+	TelnetCommand  string                 `json:"telnet_command,omitempty"`
+}
+
+// authorizedKeyResult JSON输出中一个公钥的写入和验证结果
+type authorizedKeyResult struct {
+	Path     string `json:"path"`
+	Key      string `json:"key"`
+	Verified bool   `json:"verified"`
+	Error    string `json:"error,omitempty"`
+}
+
+// meshNodeResult JSON输出中一个Mesh节点的结果，主路由没有状态
+type meshNodeResult struct {
+	routers.MeshNode
+	Status *routers.ShellStatusResult `json:"status,omitempty"`
+	Error  string                     `json:"error,omitempty"`
+}
+
+// probeResult JSON输出中一个命令通道的探测结果
+type probeResult struct {
+	Channel  string `json:"channel"`
+	Accepted bool   `json:"accepted"`
+	Executed bool   `json:"executed"`
+	Error    string `json:"error,omitempty"`
+}
+
+// errorString 错误的文本，没有错误时为空
+func errorString(err error) string {
+	if err == nil {
+		return ""
+	}
+	return err.Error()
+}
@@ -1,6 +1,7 @@
 package auth
 
 import (
+	"context"
 	"crypto/sha1"
 	"crypto/sha256"
 	"encoding/json"
@@ -23,6 +24,14 @@ const (
 	Key = "a2ffa5c9be07488bbb04a3a47d3c5f6a"
 )
 
+// HashMode 登录时加密密码使用的哈希算法，新固件使用SHA256，旧固件使用SHA1
+type HashMode string
+
+const (
+	HashSHA1   HashMode = "sha1"
+	HashSHA256 HashMode = "sha256"
+)
+
 // LoginResponse 登录响应结构
 type LoginResponse struct {
 	Code  int    `json:"code"`
@@ -71,13 +80,13 @@ func encryptPasswordSHA256(password, nonce string) string {
 }
 
 // GetStok 获取路由器的stok
-func GetStok(routerIP, password string, useSHA256 bool) (string, error) {
+func GetStok(ctx context.Context, routerIP, password string, mode HashMode) (string, error) {
 	// 生成 nonce
 	nonce := generateNonce()
 
 	// 加密密码
 	var encryptedPassword string
-	if useSHA256 {
+	if mode == HashSHA256 {
 		encryptedPassword = encryptPasswordSHA256(password, nonce)
 	} else {
 		encryptedPassword = encryptPasswordSHA1(password, nonce)
@@ -98,7 +107,7 @@ func GetStok(routerIP, password string, useSHA256 bool) (string, error) {
 	logger.Debug("加密后的密码: %s", encryptedPassword)
 
 	// 创建请求
-	req, err := http.NewRequest("POST", loginURL, strings.NewReader(formData.Encode()))
+	req, err := http.NewRequestWithContext(ctx, "POST", loginURL, strings.NewReader(formData.Encode()))
 	if err != nil {
 		return "", fmt.Errorf("创建请求失败: %v", err)
 	}
@@ -1,83 +1,62 @@
 package client
 
 import (
+	"context"
 	"fmt"
-	"strings"
+	"time"
 
 	"github.com/bamzest/xiaomi-router-shell-enabler/pkg/auth"
 	"github.com/bamzest/xiaomi-router-shell-enabler/pkg/logger"
 	"github.com/bamzest/xiaomi-router-shell-enabler/pkg/routers"
 )
 
-// RouterClient 路由器客户端接口
-type RouterClient interface {
-	// SetSystemTime 设置系统时间
-	SetSystemTime() error
-
-	// EnableSSH 启用SSH
-	EnableSSH() error
-
-	// DisableSSH 关闭SSH
-	DisableSSH() error
-
-	// VerifySSHStatus 验证SSH状态
-	VerifySSHStatus() (bool, error)
-
-	// ExecuteCustomCommand 执行自定义命令
-	ExecuteCustomCommand(command string) error
-
-	// CheckShellStatus 检查SSH和Telnet状态
-	// 返回值：总体状态(bool), 详细状态信息(string), 错误(error)
-	CheckShellStatus() (bool, string, error)
-
-	// GetSSHCommand 获取适用于此型号的SSH连接命令
-	GetSSHCommand() string
-
-	// GetTelnetCommand 获取适用于此型号的Telnet连接命令
-	GetTelnetCommand() string
-}
+// RouterClient 路由器客户端接口，定义在 routers 包中，以便各型号注册时返回
+type RouterClient = routers.RouterClient
 
 // 创建路由器客户端的工厂函数 - 使用密码而不是token
-func NewRouterClient(host, password, model string) (RouterClient, error) {
+// mode 为路由器 init_info 报告的登录哈希算法，读取不到时为空，使用型号的设置
+func NewRouterClient(ctx context.Context, host, password, model string, mode auth.HashMode) (RouterClient, error) {
 	logger.Debug("创建路由器客户端: 型号=%s, 主机=%s", model, host)
 
-	// 将型号转为小写，便于匹配
-	modelLower := strings.ToLower(model)
-
-	// 根据型号选择合适的认证方式
-	useSHA256 := true
-	if modelLower == "redmi_ax5400pro" {
-		// AX5400Pro 使用 SHA256
-		useSHA256 = true
+	// 检查路由器型号是否支持，型号名忽略大小写并接受别名
+	desc, ok := routers.LookupModel(model)
+	if !ok {
+		return nil, fmt.Errorf("不支持的路由器型号: %s", model)
 	}
-	// 其他型号可以在这里添加特殊的认证方式
 
-	// 通过密码获取 stok
-	token, err := auth.GetStok(host, password, useSHA256)
+	// 通过密码获取 stok，优先使用路由器报告的哈希算法
+	if mode == "" {
+		mode = desc.HashMode
+	}
+	token, err := auth.GetStok(ctx, host, password, mode)
 	if err != nil {
 		return nil, fmt.Errorf("获取 stok 失败: %v", err)
 	}
 
 	logger.Info("成功获取 stok: %s", token)
 
-	// 检查路由器型号是否支持
-	switch modelLower {
-	case "redmi_ax5400pro":
-		return routers.NewAX5400ProClient(host, token), nil
-	// 可以在这里添加更多型号的支持
-	// case "xiaomi_ax3600":
-	//     return routers.NewAX3600Client(host, token), nil
-	default:
-		return nil, fmt.Errorf("不支持的路由器型号: %s", model)
-	}
+	return desc.New(host, token), nil
 }
 
 // 获取支持的路由器型号列表
 func GetSupportedModels() []string {
-	// 返回所有支持的型号
-	return []string{
-		"redmi_ax5400pro",
-		// 可以在这里添加更多支持的型号
-		// "xiaomi_ax3600",
+	return routers.ModelIDs()
+}
+
+// ProbeChannels 登录后探测路由器上可用的命令通道，用于尚未支持的型号，结束后注销登录
+func ProbeChannels(ctx context.Context, host, password string, mode auth.HashMode) ([]routers.ChannelProbeResult, error) {
+	token, err := auth.GetStok(ctx, host, password, mode)
+	if err != nil {
+		return nil, fmt.Errorf("获取 stok 失败: %v", err)
+	}
+
+	results := routers.ProbeChannels(ctx, host, token)
+
+	logoutCtx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	base := &routers.BaseRouterClient{Host: host, Token: token}
+	if err := base.Logout(logoutCtx); err != nil {
+		logger.Debug("%v", err)
 	}
+	return results, nil
 }
@@ -0,0 +1,154 @@
+package config
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/bamzest/xiaomi-router-shell-enabler/pkg/utils"
+	"gopkg.in/yaml.v3"
+)
+
+// 配置文件所在的目录名和文件名，与状态文件共用目录
+const (
+	appDirName = "xiaomi-router-shell-enabler"
+	fileName   = "config.yaml"
+)
+
+// Profile 一台路由器的配置
+// 管理密码按 password_env、password_file、password 的顺序读取，建议不要在配置文件中保存明文密码
+type Profile struct {
+	Host           string   `yaml:"host"`
+	Model          string   `yaml:"model,omitempty"`
+	PasswordEnv    string   `yaml:"password_env,omitempty"`  // 保存管理密码的环境变量
+	PasswordFile   string   `yaml:"password_file,omitempty"` // 保存管理密码的文件
+	Password       string   `yaml:"password,omitempty"`      // 明文管理密码
+	SN             string   `yaml:"sn,omitempty"`
+	Services       []string `yaml:"services,omitempty"`
+	AuthorizedKeys []string `yaml:"authorized_keys,omitempty"`
+}
+
+// HasPassword 是否配置了管理密码的来源
+func (p *Profile) HasPassword() bool {
+	return p.PasswordEnv != "" || p.PasswordFile != "" || p.Password != ""
+}
+
+// ResolvePassword 按配置的来源读取管理密码
+func (p *Profile) ResolvePassword() (string, error) {
+	switch {
+	case p.PasswordEnv != "":
+		password := os.Getenv(p.PasswordEnv)
+		if password == "" {
+			return "", fmt.Errorf("环境变量 %s 未设置", p.PasswordEnv)
+		}
+		return password, nil
+	case p.PasswordFile != "":
+		content, err := os.ReadFile(utils.ExpandHome(p.PasswordFile))
+		if err != nil {
+			return "", fmt.Errorf("读取密码文件失败: %v", err)
+		}
+		password := strings.TrimSpace(string(content))
+		if password == "" {
+			return "", fmt.Errorf("密码文件 %s 为空", p.PasswordFile)
+		}
+		return password, nil
+	default:
+		return p.Password, nil
+	}
+}
+
+// Config 配置文件的内容
+type Config struct {
+	Profiles map[string]*Profile `yaml:"profiles"`
+
+	path string
+}
+
+// Path 返回配置文件路径
+func Path() (string, error) {
+	configDir, err := os.UserConfigDir()
+	if err != nil {
+		return "", fmt.Errorf("无法获取配置目录: %v", err)
+	}
+	return filepath.Join(configDir, appDirName, fileName), nil
+}
+
+// Load 读取配置文件，文件不存在时返回空配置
+func Load() (*Config, error) {
+	p, err := Path()
+	if err != nil {
+		return nil, err
+	}
+
+	cfg := &Config{Profiles: map[string]*Profile{}, path: p}
+	content, err := os.ReadFile(p)
+	if os.IsNotExist(err) {
+		return cfg, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("读取配置文件失败: %v", err)
+	}
+
+	if err := yaml.Unmarshal(content, cfg); err != nil {
+		return nil, fmt.Errorf("解析配置文件 %s 失败: %v", p, err)
+	}
+	if cfg.Profiles == nil {
+		cfg.Profiles = map[string]*Profile{}
+	}
+	for name, profile := range cfg.Profiles {
+		if profile == nil || profile.Host == "" {
+			return nil, fmt.Errorf("配置文件 %s 中的 %s 没有设置 host", p, name)
+		}
+	}
+	return cfg, nil
+}
+
+// File 配置文件路径
+func (c *Config) File() string {
+	return c.path
+}
+
+// Profile 按名称查找配置
+func (c *Config) Profile(name string) (*Profile, error) {
+	if profile, ok := c.Profiles[name]; ok {
+		return profile, nil
+	}
+	if len(c.Profiles) == 0 {
+		return nil, fmt.Errorf("没有名为 %s 的配置，配置文件 %s 中还没有任何配置", name, c.path)
+	}
+	return nil, fmt.Errorf("没有名为 %s 的配置，可用的配置: %s", name, strings.Join(c.Names(), ", "))
+}
+
+// Names 所有配置的名称
+func (c *Config) Names() []string {
+	names := make([]string, 0, len(c.Profiles))
+	for name := range c.Profiles {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// Save 写入配置文件，其中可能包含密码，只允许当前用户读写
+func (c *Config) Save() error {
+	var buf bytes.Buffer
+	encoder := yaml.NewEncoder(&buf)
+	encoder.SetIndent(2)
+	if err := encoder.Encode(c); err != nil {
+		return fmt.Errorf("生成配置文件失败: %v", err)
+	}
+	if err := os.MkdirAll(filepath.Dir(c.path), 0700); err != nil {
+		return fmt.Errorf("创建配置目录失败: %v", err)
+	}
+	if err := os.WriteFile(c.path, buf.Bytes(), 0600); err != nil {
+		return fmt.Errorf("写入配置文件失败: %v", err)
+	}
+	// 文件已存在时 WriteFile 不会修改权限
+	if err := os.Chmod(c.path, 0600); err != nil {
+		return fmt.Errorf("设置配置文件权限失败: %v", err)
+	}
+	return nil
+}
@@ -0,0 +1,157 @@
+package firmware
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"hash/crc32"
+	"strings"
+)
+
+// 小米固件镜像的文件头魔数
+var imageMagic = []byte("HDR1")
+
+// 分段头的魔数
+const blobMagic = 0xBABE
+
+// 文件头和分段头的大小
+const (
+	imageHeaderSize = 48
+	blobHeaderSize  = 48
+	maxBlobs        = 8
+)
+
+// 分段内容的类型，由内容的魔数判断
+const (
+	KindUBI      = "UBI"
+	KindSquashfs = "squashfs"
+	KindKernel   = "kernel"
+	KindUnknown  = "unknown"
+)
+
+// Image 解析后的小米固件镜像
+//
+// 文件头 (小端):
+//
+//	magic[4] "HDR1" | signature_offset u32 | crc32 u32 | unused u16 | device_id u16 | blob_offsets[8] u32
+//
+// 每个分段头 (小端)，其后紧跟分段数据:
+//
+//	magic u16 0xBABE | unused u16 | flash_addr u32 | size u32 | type u16 | unused u16 | name[32]
+type Image struct {
+	DeviceID        uint16 `json:"device_id"`
+	CRC32           uint32 `json:"crc32"`
+	ComputedCRC32   uint32 `json:"computed_crc32"` // 对 crc32 字段之后的全部内容计算的 CRC32
+	SignatureOffset uint32 `json:"signature_offset"`
+	SignatureSize   uint32 `json:"signature_size"`
+	Blobs           []Blob `json:"blobs"`
+}
+
+// Blob 固件镜像中的一个分段
+type Blob struct {
+	Name      string      `json:"name"`
+	Type      uint16      `json:"type"`
+	FlashAddr uint32      `json:"flash_addr"`
+	Offset    uint32      `json:"offset"` // 分段数据在镜像文件中的偏移
+	Size      uint32      `json:"size"`
+	Kind      string      `json:"kind"`
+	Volumes   []UBIVolume `json:"volumes,omitempty"` // Kind 为 UBI 时的卷
+	Data      []byte      `json:"-"`
+}
+
+// CRCMatches 文件头中的CRC是否与计算结果一致
+func (img *Image) CRCMatches() bool {
+	return img.CRC32 == img.ComputedCRC32
+}
+
+// ParseImage 解析小米固件镜像的文件头和分段
+func ParseImage(data []byte) (*Image, error) {
+	if len(data) < imageHeaderSize || !bytes.HasPrefix(data, imageMagic) {
+		return nil, fmt.Errorf("不是小米固件镜像 (缺少 HDR1 文件头)")
+	}
+
+	img := &Image{
+		SignatureOffset: binary.LittleEndian.Uint32(data[4:8]),
+		CRC32:           binary.LittleEndian.Uint32(data[8:12]),
+		DeviceID:        binary.LittleEndian.Uint16(data[14:16]),
+		ComputedCRC32:   crc32.ChecksumIEEE(data[12:]),
+	}
+
+	// 签名块: size u32 | padding[12] | 签名数据
+	if sig := int(img.SignatureOffset); sig > 0 && sig+16 <= len(data) {
+		img.SignatureSize = binary.LittleEndian.Uint32(data[sig : sig+4])
+		if sig+16+int(img.SignatureSize) > len(data) {
+			return nil, fmt.Errorf("签名块超出文件范围 (偏移 0x%x，大小 0x%x)", sig, img.SignatureSize)
+		}
+	}
+
+	for i := 0; i < maxBlobs; i++ {
+		off := int(binary.LittleEndian.Uint32(data[16+i*4 : 20+i*4]))
+		if off == 0 {
+			continue
+		}
+		blob, err := parseBlob(data, off)
+		if err != nil {
+			return nil, fmt.Errorf("解析第 %d 个分段失败: %v", i+1, err)
+		}
+		img.Blobs = append(img.Blobs, *blob)
+	}
+	if len(img.Blobs) == 0 {
+		return nil, fmt.Errorf("固件镜像中没有分段")
+	}
+	return img, nil
+}
+
+// parseBlob 解析偏移 off 处的分段
+func parseBlob(data []byte, off int) (*Blob, error) {
+	if off+blobHeaderSize > len(data) {
+		return nil, fmt.Errorf("分段头超出文件范围 (偏移 0x%x)", off)
+	}
+	header := data[off : off+blobHeaderSize]
+	if magic := binary.LittleEndian.Uint16(header[0:2]); magic != blobMagic {
+		return nil, fmt.Errorf("偏移 0x%x 处的分段魔数无效: 0x%04x", off, magic)
+	}
+
+	blob := &Blob{
+		FlashAddr: binary.LittleEndian.Uint32(header[4:8]),
+		Size:      binary.LittleEndian.Uint32(header[8:12]),
+		Type:      binary.LittleEndian.Uint16(header[12:14]),
+		Name:      strings.TrimRight(string(header[16:48]), "\x00"),
+		Offset:    uint32(off + blobHeaderSize),
+	}
+	end := int(blob.Offset) + int(blob.Size)
+	if end > len(data) {
+		return nil, fmt.Errorf("分段 %s 超出文件范围 (偏移 0x%x，大小 0x%x)", blob.Name, blob.Offset, blob.Size)
+	}
+	blob.Data = data[blob.Offset:end]
+
+	switch {
+	case bytes.HasPrefix(blob.Data, ubiECMagic):
+		blob.Kind = KindUBI
+		volumes, err := ExtractUBIVolumes(blob.Data)
+		if err != nil {
+			return nil, fmt.Errorf("解析分段 %s 中的UBI失败: %v", blob.Name, err)
+		}
+		blob.Volumes = volumes
+	default:
+		blob.Kind = contentKind(blob.Data)
+	}
+	return blob, nil
+}
+
+// Kind 判断UBI卷的内容
+func (v UBIVolume) Kind() string {
+	return contentKind(v.Data)
+}
+
+// contentKind 根据魔数判断内核或文件系统，uImage 和 FIT 格式均视为内核
+func contentKind(data []byte) string {
+	switch {
+	case bytes.HasPrefix(data, squashfsMagic):
+		return KindSquashfs
+	case bytes.HasPrefix(data, []byte{0x27, 0x05, 0x19, 0x56}), bytes.HasPrefix(data, []byte{0xd0, 0x0d, 0xfe, 0xed}):
+		return KindKernel
+	default:
+		return KindUnknown
+	}
+}
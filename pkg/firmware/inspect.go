@@ -0,0 +1,116 @@
+package firmware
+
+import (
+	"fmt"
+	"os"
+	"regexp"
+	"strings"
+
+	"github.com/bamzest/xiaomi-router-shell-enabler/pkg/logger"
+)
+
+// rootfs 中记录固件版本的文件和 dropbear 启动脚本
+const (
+	versionFile  = "/usr/share/xiaoqiang/xiaoqiang_version"
+	dropbearInit = "/etc/init.d/dropbear"
+)
+
+// xiaoqiang_version 中的 option KEY 'VALUE'
+var versionOption = regexp.MustCompile(`option\s+(\w+)\s+'([^']*)'`)
+
+// dropbear 启动脚本中按固件渠道拒绝启动的判断，如 [ "$flg_ssh" != "1" -o "$channel" = "release" ]
+// 启用SSH时配方用 sed s/release/debug/ 修改的就是这里的 release
+var dropbearReleaseGate = regexp.MustCompile(`\$\{?channel\}?"?\s*==?\s*"?release\b|\brelease"?\s*==?\s*"?\$\{?channel\b`)
+
+// Inspection 固件镜像的检查结果
+type Inspection struct {
+	Image *Image `json:"image"`
+
+	// 以下内容来自 rootfs，RootfsErr 不为空时无法读取
+	RomVersion string `json:"rom_version,omitempty"`
+	Channel    string `json:"channel,omitempty"`
+	Hardware   string `json:"hardware,omitempty"`
+
+	// DropbearGated dropbear 启动脚本在 release 版固件中拒绝启动，启用SSH时需要修改
+	DropbearFound bool `json:"dropbear_found"`
+	DropbearGated bool `json:"dropbear_gated"`
+
+	RootfsErr error `json:"-"`
+}
+
+// InspectImage 解析固件镜像，并从其中的 rootfs 读取固件版本和 dropbear 启动脚本
+// 解包 rootfs 需要系统中有 unsquashfs，解包失败时只返回文件头信息
+func InspectImage(path string) (*Inspection, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("读取文件失败: %v", err)
+	}
+
+	img, err := ParseImage(data)
+	if err != nil {
+		return nil, err
+	}
+	result := &Inspection{Image: img}
+
+	rootfs := findRootfs(img)
+	if rootfs == nil {
+		result.RootfsErr = fmt.Errorf("固件中没有squashfs文件系统")
+		return result, nil
+	}
+
+	files, err := extractSquashfsFiles(rootfs, "xiaoqiang_version", "dropbear")
+	if err != nil {
+		result.RootfsErr = err
+		return result, nil
+	}
+
+	if content, ok := files[versionFile]; ok {
+		for _, m := range versionOption.FindAllStringSubmatch(string(content), -1) {
+			switch m[1] {
+			case "ROM":
+				result.RomVersion = m[2]
+			case "CHANNEL":
+				result.Channel = m[2]
+			case "HARDWARE":
+				result.Hardware = m[2]
+			}
+		}
+	} else {
+		logger.Warn("固件中没有 %s", versionFile)
+	}
+
+	if content, ok := files[dropbearInit]; ok {
+		result.DropbearFound = true
+		result.DropbearGated = dropbearGated(string(content))
+	}
+	return result, nil
+}
+
+// dropbearGated 启动脚本中是否有按 release 渠道拒绝启动的判断，注释中的内容不算
+func dropbearGated(script string) bool {
+	for _, line := range strings.Split(script, "\n") {
+		line = strings.TrimSpace(line)
+		if strings.HasPrefix(line, "#") {
+			continue
+		}
+		if dropbearReleaseGate.MatchString(line) {
+			return true
+		}
+	}
+	return false
+}
+
+// findRootfs 返回分段或UBI卷中的第一个squashfs
+func findRootfs(img *Image) []byte {
+	for _, blob := range img.Blobs {
+		if blob.Kind == KindSquashfs {
+			return findSquashfs(blob.Data)
+		}
+		for _, vol := range blob.Volumes {
+			if vol.Kind() == KindSquashfs {
+				return findSquashfs(vol.Data)
+			}
+		}
+	}
+	return nil
+}
@@ -0,0 +1,40 @@
+package firmware
+
+import (
+	"strings"
+	"testing"
+)
+
+// 小米固件 /etc/init.d/dropbear 中的判断
+const dropbearInitScript = `#!/bin/sh /etc/rc.common
+# only start dropbear on non-release firmware
+START=50
+
+start_service() {
+	flg_ssh=$(nvram get ssh_en)
+	channel=$(/sbin/uci get /usr/share/xiaoqiang/xiaoqiang_version.version.CHANNEL)
+	if [ "$flg_ssh" != "1" -o "$channel" = "release" ]; then
+		return 0
+	fi
+	procd_open_instance
+}
+`
+
+func TestDropbearGated(t *testing.T) {
+	for _, tc := range []struct {
+		name   string
+		script string
+		want   bool
+	}{
+		{"原始脚本", dropbearInitScript, true},
+		{"配方修改后", strings.ReplaceAll(dropbearInitScript, "release", "debug"), false},
+		{"变量加花括号", `[ "${channel}" == "release" ] && exit 0`, true},
+		{"比较顺序相反", `if [ release = $channel ]; then return 0; fi`, true},
+		{"只在注释中", "# release 版固件不启动\nstart_service() { :; }", false},
+		{"无关的变量", "RELEASE_NOTES=/tmp/release.txt\necho release", false},
+	} {
+		if got := dropbearGated(tc.script); got != tc.want {
+			t.Errorf("%s: dropbearGated() = %v，期望 %v", tc.name, got, tc.want)
+		}
+	}
+}
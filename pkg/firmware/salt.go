@@ -0,0 +1,149 @@
+package firmware
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/bamzest/xiaomi-router-shell-enabler/pkg/logger"
+	"github.com/bamzest/xiaomi-router-shell-enabler/pkg/utils"
+)
+
+// 固件中 mkxqimage 可能的文件名
+var mkxqimageNames = []string{"mkxqimage"}
+
+// ELF 文件的魔数
+var elfMagic = []byte("\x7fELF")
+
+// UUID 形式的盐，新固件中的盐按 '-' 分段反向存放 (12-4-4-4-8)
+var saltPattern = regexp.MustCompile(`\b(?:[0-9A-Fa-f]{8}-[0-9A-Fa-f]{4}-[0-9A-Fa-f]{4}-[0-9A-Fa-f]{4}-[0-9A-Fa-f]{12}|[0-9A-Fa-f]{12}-[0-9A-Fa-f]{4}-[0-9A-Fa-f]{4}-[0-9A-Fa-f]{4}-[0-9A-Fa-f]{8})\b`)
+
+// SaltCandidate 在文件中找到的一个候选盐
+type SaltCandidate struct {
+	Salt   string `json:"salt"`            // 文件中存放的原始形式，与 utils 中算法的 Salt 字段一致
+	Swap   bool   `json:"swap"`            // 分段反向存放，使用前需要反转
+	Source string `json:"source"`          // 所在的文件
+	Offset int    `json:"offset"`          // 在所在文件中的偏移
+	Known  string `json:"known,omitempty"` // 与之相同的已注册算法，为空表示新的盐
+}
+
+// Description 候选盐的说明
+func (c SaltCandidate) Description() string {
+	layout := "8-4-4-4-12，直接使用 (Swap: false)"
+	if c.Swap {
+		layout = "12-4-4-4-8，使用前需要反转 (Swap: true)"
+	}
+	known := "未注册的新盐"
+	if c.Known != "" {
+		known = fmt.Sprintf("与算法 %s 的盐相同", c.Known)
+	}
+	return fmt.Sprintf("%s\n     位置: %s 偏移 0x%x\n     格式: %s\n     %s", c.Salt, c.Source, c.Offset, layout, known)
+}
+
+// ExtractSalts 从 mkxqimage 程序或固件镜像中查找UUID形式的候选盐
+// 固件镜像中的 mkxqimage 位于 UBI 卷内的 squashfs 中，需要系统中有 unsquashfs 才能解包
+func ExtractSalts(path string) ([]SaltCandidate, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("读取文件失败: %v", err)
+	}
+
+	if bytes.HasPrefix(data, elfMagic) {
+		logger.Info("%s 是可执行文件，直接查找盐", path)
+		return scanSalts(data, path), nil
+	}
+
+	files, err := findMkxqimage(data)
+	if err != nil {
+		return nil, err
+	}
+
+	var candidates []SaltCandidate
+	for _, name := range sortedKeys(files) {
+		logger.Info("在固件中找到 %s", name)
+		candidates = append(candidates, scanSalts(files[name], name)...)
+	}
+	return candidates, nil
+}
+
+// findMkxqimage 在固件镜像的squashfs中查找 mkxqimage
+func findMkxqimage(data []byte) (map[string][]byte, error) {
+	var images [][]byte
+	if volumes, err := ExtractUBIVolumes(data); err == nil {
+		for _, vol := range volumes {
+			if image := findSquashfs(vol.Data); image != nil {
+				logger.Info("UBI卷 %d 中包含squashfs", vol.ID)
+				images = append(images, image)
+			}
+		}
+	} else {
+		logger.Debug("未能解析UBI: %v", err)
+		if image := findSquashfs(data); image != nil {
+			logger.Info("固件中包含squashfs")
+			images = append(images, image)
+		}
+	}
+	if len(images) == 0 {
+		return nil, fmt.Errorf("无法识别文件格式：既不是可执行文件，也没有找到UBI或squashfs")
+	}
+
+	files := map[string][]byte{}
+	for _, image := range images {
+		found, err := extractSquashfsFiles(image, mkxqimageNames...)
+		if err != nil {
+			return nil, fmt.Errorf("%v，也可以直接指定解包得到的 mkxqimage", err)
+		}
+		for name, content := range found {
+			files[name] = content
+		}
+	}
+	if len(files) == 0 {
+		return nil, fmt.Errorf("固件的文件系统中没有 mkxqimage")
+	}
+	return files, nil
+}
+
+// scanSalts 查找数据中所有UUID形式的字符串，相同的盐只保留第一次出现的位置
+func scanSalts(data []byte, source string) []SaltCandidate {
+	seen := map[string]bool{}
+	var candidates []SaltCandidate
+	for _, loc := range saltPattern.FindAllIndex(data, -1) {
+		salt := string(data[loc[0]:loc[1]])
+		if seen[salt] {
+			continue
+		}
+		seen[salt] = true
+
+		candidates = append(candidates, SaltCandidate{
+			Salt:   salt,
+			Swap:   strings.Index(salt, "-") == 12,
+			Source: source,
+			Offset: loc[0],
+			Known:  knownSalt(salt),
+		})
+	}
+	logger.Debug("%s 中找到 %d 个候选盐", source, len(candidates))
+	return candidates
+}
+
+// knownSalt 返回使用相同盐的已注册算法
+func knownSalt(salt string) string {
+	for _, a := range utils.PasswordAlgorithms() {
+		if strings.EqualFold(a.Salt, salt) {
+			return a.Name
+		}
+	}
+	return ""
+}
+
+func sortedKeys(m map[string][]byte) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
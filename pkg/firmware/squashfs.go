@@ -0,0 +1,82 @@
+package firmware
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+
+	"github.com/bamzest/xiaomi-router-shell-enabler/pkg/logger"
+)
+
+// squashfs 超级块的魔数 (小端)
+var squashfsMagic = []byte("hsqs")
+
+// findSquashfs 返回数据中的squashfs镜像，按超级块中记录的大小截取
+func findSquashfs(data []byte) []byte {
+	off := bytes.Index(data, squashfsMagic)
+	if off < 0 || off+96 > len(data) {
+		return nil
+	}
+	image := data[off:]
+	if used := binary.LittleEndian.Uint64(image[40:48]); used > 0 && used <= uint64(len(image)) {
+		image = image[:used]
+	}
+	logger.Debug("在偏移 0x%x 处找到squashfs，大小 0x%x", off, len(image))
+	return image
+}
+
+// extractSquashfsFiles 用系统的 unsquashfs 解包镜像，返回文件名与 names 相同的文件内容
+// squashfs 通常使用xz压缩，不解包无法在其中找到盐
+func extractSquashfsFiles(image []byte, names ...string) (map[string][]byte, error) {
+	unsquashfs, err := exec.LookPath("unsquashfs")
+	if err != nil {
+		return nil, fmt.Errorf("需要 unsquashfs 解包固件中的squashfs (通常在 squashfs-tools 软件包中)")
+	}
+
+	dir, err := os.MkdirTemp("", "xiaomi-firmware-")
+	if err != nil {
+		return nil, fmt.Errorf("创建临时目录失败: %v", err)
+	}
+	defer os.RemoveAll(dir)
+
+	imagePath := filepath.Join(dir, "rootfs.squashfs")
+	if err := os.WriteFile(imagePath, image, 0600); err != nil {
+		return nil, fmt.Errorf("写入squashfs镜像失败: %v", err)
+	}
+
+	rootDir := filepath.Join(dir, "rootfs")
+	cmd := exec.Command(unsquashfs, "-no-progress", "-d", rootDir, imagePath)
+	if output, err := cmd.CombinedOutput(); err != nil {
+		// 设备文件等无法创建时 unsquashfs 也会返回错误，只要解出了文件就继续
+		logger.Debug("unsquashfs 输出: %s", string(output))
+		if _, statErr := os.Stat(rootDir); statErr != nil {
+			return nil, fmt.Errorf("解包squashfs失败: %v", err)
+		}
+		logger.Warn("unsquashfs 报告了错误，继续在已解出的文件中查找: %v", err)
+	}
+
+	wanted := map[string]bool{}
+	for _, name := range names {
+		wanted[name] = true
+	}
+	files := map[string][]byte{}
+	err = filepath.Walk(rootDir, func(path string, info os.FileInfo, err error) error {
+		if err != nil || !info.Mode().IsRegular() || !wanted[info.Name()] {
+			return nil
+		}
+		content, err := os.ReadFile(path)
+		if err != nil {
+			return fmt.Errorf("读取 %s 失败: %v", path, err)
+		}
+		rel, _ := filepath.Rel(rootDir, path)
+		files["/"+filepath.ToSlash(rel)] = content
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return files, nil
+}
@@ -0,0 +1,157 @@
+package firmware
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"sort"
+
+	"github.com/bamzest/xiaomi-router-shell-enabler/pkg/logger"
+)
+
+// UBI 擦除块头和卷标识头的魔数
+var (
+	ubiECMagic  = []byte("UBI#")
+	ubiVIDMagic = []byte("UBI!")
+)
+
+// 擦除块头和卷标识头的大小
+const ubiHeaderSize = 64
+
+// UBI 内部的卷布局表，不包含用户数据
+const ubiLayoutVolumeID = 0x7FFFEFFF
+
+// 卷表中每条记录的大小
+const ubiVTBLRecordSize = 172
+
+// UBIVolume 从UBI镜像中重组出的一个卷
+type UBIVolume struct {
+	ID   uint32 `json:"id"`
+	Name string `json:"name"` // 卷表中记录的名称，如 kernel、ubi_rootfs
+	Data []byte `json:"-"`
+}
+
+// ubiLEB 一个逻辑擦除块
+type ubiLEB struct {
+	lnum  uint32
+	sqnum uint64
+	data  []byte
+}
+
+// ExtractUBIVolumes 按卷号和逻辑块号重组UBI镜像中的卷
+// UBI 中卷的数据分散在各个物理擦除块里，每块都带有头部，不能直接当作文件系统读取
+func ExtractUBIVolumes(image []byte) ([]UBIVolume, error) {
+	start := bytes.Index(image, ubiECMagic)
+	if start < 0 {
+		return nil, fmt.Errorf("没有找到UBI擦除块头")
+	}
+	image = image[start:]
+
+	pebSize, err := ubiPEBSize(image)
+	if err != nil {
+		return nil, err
+	}
+	logger.Debug("UBI镜像起始于偏移 0x%x，擦除块大小 0x%x", start, pebSize)
+
+	lebs := map[uint32]map[uint32]ubiLEB{}
+	var names map[uint32]string
+	for off := 0; off+pebSize <= len(image); off += pebSize {
+		peb := image[off : off+pebSize]
+		if !bytes.HasPrefix(peb, ubiECMagic) {
+			continue
+		}
+		// 偏移来自镜像内容，需要确认卷标识头和数据都在擦除块之内
+		vidOffset := int64(binary.BigEndian.Uint32(peb[16:20]))
+		dataOffset := int64(binary.BigEndian.Uint32(peb[20:24]))
+		if vidOffset < ubiHeaderSize || vidOffset+ubiHeaderSize > int64(len(peb)) ||
+			dataOffset < vidOffset+ubiHeaderSize || dataOffset > int64(len(peb)) {
+			logger.Debug("偏移 0x%x 处的擦除块头无效", start+off)
+			continue
+		}
+
+		vid := peb[vidOffset : vidOffset+ubiHeaderSize]
+		if !bytes.HasPrefix(vid, ubiVIDMagic) {
+			// 空闲块没有卷标识头
+			continue
+		}
+		volID := binary.BigEndian.Uint32(vid[8:12])
+		if volID == ubiLayoutVolumeID {
+			if names == nil {
+				names = ubiVolumeNames(peb[dataOffset:])
+			}
+			continue
+		}
+		leb := ubiLEB{
+			lnum:  binary.BigEndian.Uint32(vid[12:16]),
+			sqnum: binary.BigEndian.Uint64(vid[40:48]),
+			data:  peb[dataOffset:],
+		}
+		// 静态卷的最后一块只有 data_size 字节有效
+		if vid[5] == 2 {
+			if size := int(binary.BigEndian.Uint32(vid[20:24])); size > 0 && size <= len(leb.data) {
+				leb.data = leb.data[:size]
+			}
+		}
+
+		if lebs[volID] == nil {
+			lebs[volID] = map[uint32]ubiLEB{}
+		}
+		// 同一逻辑块有多份时以序号大的为准
+		if existing, ok := lebs[volID][leb.lnum]; !ok || leb.sqnum > existing.sqnum {
+			lebs[volID][leb.lnum] = leb
+		}
+	}
+
+	if len(lebs) == 0 {
+		return nil, fmt.Errorf("UBI镜像中没有数据卷")
+	}
+
+	volumes := make([]UBIVolume, 0, len(lebs))
+	for id, blocks := range lebs {
+		lnums := make([]uint32, 0, len(blocks))
+		for lnum := range blocks {
+			lnums = append(lnums, lnum)
+		}
+		sort.Slice(lnums, func(i, j int) bool { return lnums[i] < lnums[j] })
+
+		var data []byte
+		for _, lnum := range lnums {
+			data = append(data, blocks[lnum].data...)
+		}
+		volumes = append(volumes, UBIVolume{ID: id, Name: names[id], Data: data})
+	}
+	sort.Slice(volumes, func(i, j int) bool { return volumes[i].ID < volumes[j].ID })
+	return volumes, nil
+}
+
+// ubiPEBSize 由相邻两个擦除块头的距离推算物理擦除块大小
+// 擦除块至少要容纳擦除块头和卷标识头，因此只在第一个擦除块头之后查找下一个
+func ubiPEBSize(image []byte) (int, error) {
+	if len(image) < 2*ubiHeaderSize {
+		return 0, fmt.Errorf("UBI镜像不完整")
+	}
+	for _, size := range []int{0x10000, 0x20000, 0x40000, 0x80000} {
+		if size < len(image) && bytes.HasPrefix(image[size:], ubiECMagic) {
+			return size, nil
+		}
+	}
+	next := bytes.Index(image[2*ubiHeaderSize:], ubiECMagic)
+	if next < 0 {
+		return 0, fmt.Errorf("无法确定UBI擦除块大小")
+	}
+	return next + 2*ubiHeaderSize, nil
+}
+
+// ubiVolumeNames 从卷表中读取各卷的名称
+func ubiVolumeNames(vtbl []byte) map[uint32]string {
+	names := map[uint32]string{}
+	for id := uint32(0); int(id+1)*ubiVTBLRecordSize <= len(vtbl) && id < 128; id++ {
+		record := vtbl[int(id)*ubiVTBLRecordSize : int(id+1)*ubiVTBLRecordSize]
+		nameLen := int(binary.BigEndian.Uint16(record[14:16]))
+		if nameLen == 0 || nameLen > 127 {
+			continue
+		}
+		names[id] = string(record[16 : 16+nameLen])
+	}
+	return names
+}
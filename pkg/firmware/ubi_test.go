@@ -0,0 +1,57 @@
+package firmware
+
+import (
+	"bytes"
+	"encoding/binary"
+	"testing"
+)
+
+// ubiPEB 构造一个擦除块，卷标识头位于 64，数据位于 128
+func ubiPEB(size int, volID, lnum uint32, data byte) []byte {
+	peb := make([]byte, size)
+	copy(peb, ubiECMagic)
+	binary.BigEndian.PutUint32(peb[16:20], ubiHeaderSize)
+	binary.BigEndian.PutUint32(peb[20:24], 2*ubiHeaderSize)
+
+	vid := peb[ubiHeaderSize:]
+	copy(vid, ubiVIDMagic)
+	vid[5] = 1
+	binary.BigEndian.PutUint32(vid[8:12], volID)
+	binary.BigEndian.PutUint32(vid[12:16], lnum)
+	binary.BigEndian.PutUint64(vid[40:48], uint64(lnum))
+	for i := 2 * ubiHeaderSize; i < size; i++ {
+		peb[i] = data
+	}
+	return peb
+}
+
+func TestExtractUBIVolumes(t *testing.T) {
+	image := append(ubiPEB(256, 3, 1, 'b'), ubiPEB(256, 3, 0, 'a')...)
+	volumes, err := ExtractUBIVolumes(image)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(volumes) != 1 || volumes[0].ID != 3 {
+		t.Fatalf("卷为 %+v，期望只有卷 3", volumes)
+	}
+	want := append(bytes.Repeat([]byte("a"), 128), bytes.Repeat([]byte("b"), 128)...)
+	if !bytes.Equal(volumes[0].Data, want) {
+		t.Errorf("卷数据没有按逻辑块号重组")
+	}
+}
+
+func TestExtractUBIVolumesMalformed(t *testing.T) {
+	badOffsets := ubiPEB(256, 3, 0, 'a')
+	binary.BigEndian.PutUint32(badOffsets[16:20], 0xfffffff0)
+	binary.BigEndian.PutUint32(badOffsets[20:24], 0xffffffff)
+
+	for name, image := range map[string][]byte{
+		"擦除块头过密":  bytes.Repeat([]byte("UBI#xxxx"), 8),
+		"擦除块小于头部": bytes.Repeat(append([]byte("UBI#"), make([]byte, 60)...), 4),
+		"偏移越界":    append(badOffsets, badOffsets...),
+	} {
+		if _, err := ExtractUBIVolumes(image); err == nil {
+			t.Errorf("%s: 期望返回错误", name)
+		}
+	}
+}
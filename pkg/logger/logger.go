@@ -3,6 +3,7 @@ package logger
 import (
 	"fmt"
 	"github.com/fatih/color"
+	"golang.org/x/term"
 	"log"
 	"os"
 )
@@ -17,7 +18,12 @@ const (
 
 var (
 	currentLevel = LevelInfo
-	logger       = log.New(os.Stdout, "", 0)
+
+	// 日志输出到标准错误，标准输出只用于命令的结果
+	logger = log.New(os.Stderr, "", 0)
+
+	// 每条错误日志都会传给 errorHook，用于在结构化输出中汇总错误
+	errorHook func(message string)
 	
 	// 颜色输出
 	debugColor = color.New(color.FgCyan)
@@ -26,6 +32,17 @@ var (
 	errorColor = color.New(color.FgRed, color.Bold)
 )
 
+func init() {
+	// color 默认按标准输出判断是否为终端，日志输出到标准错误，需要按标准错误重新判断
+	// 这样在 "| jq" 等管道中使用时日志仍然有颜色
+	color.NoColor = os.Getenv("NO_COLOR") != "" || os.Getenv("TERM") == "dumb" || !term.IsTerminal(int(os.Stderr.Fd()))
+}
+
+// SetErrorHook 设置接收错误日志的函数，为空时取消
+func SetErrorHook(hook func(message string)) {
+	errorHook = hook
+}
+
 // SetLevel 设置日志级别
 func SetLevel(level int) {
 	currentLevel = level
@@ -57,8 +74,11 @@ func Warn(format string, args ...interface{}) {
 
 // Error 输出错误日志
 func Error(format string, args ...interface{}) {
+	message := fmt.Sprintf(format, args...)
+	if errorHook != nil {
+		errorHook(message)
+	}
 	if currentLevel <= LevelError {
-		message := fmt.Sprintf(format, args...)
 		logger.Println(errorColor.Sprintf("[ERROR] %s", message))
 	}
 }
\ No newline at end of file
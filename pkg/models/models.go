@@ -3,5 +3,7 @@ package models
 // 支持的路由器型号常量
 const (
 	ModelAX5400Pro = "redmi_ax5400pro"
+	ModelAX3600    = "xiaomi_ax3600"
+	ModelAX6000    = "xiaomi_ax6000"
 	// 可以在这里添加更多型号
-)
\ No newline at end of file
+)
@@ -0,0 +1,42 @@
+package routers
+
+import (
+	"github.com/bamzest/xiaomi-router-shell-enabler/pkg/auth"
+	"github.com/bamzest/xiaomi-router-shell-enabler/pkg/models"
+)
+
+// AX3600Client 小米AX3600路由器客户端
+type AX3600Client struct {
+	recipeClient
+}
+
+// AX3600 支持的命令通道，按尝试顺序排列
+var ax3600Channels = []string{ChannelIoTDev}
+
+func init() {
+	RegisterModel(ModelDescriptor{
+		ID:          models.ModelAX3600,
+		Aliases:     []string{"ax3600"},
+		DisplayName: "Xiaomi AX3600",
+		Hardware:    []string{"R3600"},
+		HashMode:    auth.HashSHA1,
+		// 只记录经过确认的版本，其他版本按未测试处理；尚无已确认被修补的版本
+		Firmware: []FirmwareRange{
+			{Min: "1.0.17", Max: "1.0.17", Status: FirmwareWorking, Note: "set_config_iotdev 的 ssid 参数可以注入命令，社区刷机教程要求先降级到此版本"},
+		},
+		Channels:     ax3600Channels,
+		Capabilities: recipeClientCapabilities,
+		New: func(host, token string) RouterClient {
+			return NewAX3600Client(host, token)
+		},
+	})
+}
+
+// NewAX3600Client 创建AX3600客户端
+func NewAX3600Client(host, token string) *AX3600Client {
+	c := &AX3600Client{
+		recipeClient: newRecipeClient(host, token, models.ModelAX3600, "-o HostKeyAlgorithms=+ssh-rsa"),
+	}
+	c.useChannels(ax3600Channels...)
+	return c
+}
@@ -0,0 +1,43 @@
+package routers
+
+import (
+	"github.com/bamzest/xiaomi-router-shell-enabler/pkg/auth"
+	"github.com/bamzest/xiaomi-router-shell-enabler/pkg/models"
+)
+
+// AX6000Client 小米AX6000路由器客户端
+type AX6000Client struct {
+	recipeClient
+}
+
+// AX6000 支持的命令通道，按尝试顺序排列
+// 新固件修补了IoT配网接口，但仍可能保留智能场景接口
+var ax6000Channels = []string{ChannelIoTDev, ChannelSmartController}
+
+func init() {
+	RegisterModel(ModelDescriptor{
+		ID:          models.ModelAX6000,
+		Aliases:     []string{"ax6000"},
+		DisplayName: "Xiaomi AX6000",
+		Hardware:    []string{"RA72"},
+		// init_info 报告 newEncryptMode=1，能读取 init_info 时以路由器报告的为准
+		HashMode: auth.HashSHA256,
+		// 社区的 1.0.55 解锁方法使用 arn_switch 接口，不是这里的命令通道，尚无经过确认的固件版本记录
+		Firmware:     []FirmwareRange{},
+		Channels:     ax6000Channels,
+		Capabilities: recipeClientCapabilities,
+		New: func(host, token string) RouterClient {
+			return NewAX6000Client(host, token)
+		},
+	})
+}
+
+// NewAX6000Client 创建AX6000客户端
+func NewAX6000Client(host, token string) *AX6000Client {
+	c := &AX6000Client{
+		recipeClient: newRecipeClient(host, token, models.ModelAX6000,
+			"-o HostKeyAlgorithms=+ssh-rsa -o PubkeyAcceptedKeyTypes=+ssh-rsa"),
+	}
+	c.useChannels(ax6000Channels...)
+	return c
+}
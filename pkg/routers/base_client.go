@@ -2,10 +2,13 @@ package routers
 
 import (
 	"bytes"
+	"context"
+	"encoding/json"
 	"fmt"
 	"io"
 	"net"
 	"net/http"
+	"strconv"
 	"strings"
 	"time"
 
@@ -17,18 +20,37 @@ type BaseRouterClient struct {
 	Host  string
 	Token string
 	Model string
+
+	channels []CommandChannel // 型号支持的命令通道，按尝试顺序排列
+	active   CommandChannel   // 已确认可用或由配方指定的通道
+}
+
+// APIResponse 小米路由器API通用响应结构
+type APIResponse struct {
+	Code int         `json:"code"`
+	Msg  string      `json:"msg"`
+	Data interface{} `json:"data"`
 }
 
 // ShellStatusResult 存储Shell状态检查的结果
 type ShellStatusResult struct {
-	SSHEnabled     bool // API返回的SSH状态
-	TelnetEnabled  bool // API返回的Telnet状态
-	SSHPortOpen    bool // 22端口是否开放
-	TelnetPortOpen bool // 23端口是否开放
+	SSHEnabled     bool `json:"ssh_enabled"`      // API返回的SSH状态
+	TelnetEnabled  bool `json:"telnet_enabled"`   // API返回的Telnet状态
+	SSHPortOpen    bool `json:"ssh_port_open"`    // 22端口是否开放
+	TelnetPortOpen bool `json:"telnet_port_open"` // 23端口是否开放
+}
+
+// ShellResult 启用/关闭操作的结果
+type ShellResult struct {
+	Operation string             `json:"operation"`
+	Services  []Service          `json:"services"`
+	Steps     []StepResult       `json:"steps"`
+	Status    *ShellStatusResult `json:"status,omitempty"` // 操作后的状态，无法读取时为空
+	Details   string             `json:"-"`                // 格式化的状态说明
 }
 
 // HTTP GET请求
-func (c *BaseRouterClient) Get(apiPath string) ([]byte, error) {
+func (c *BaseRouterClient) Get(ctx context.Context, apiPath string) ([]byte, error) {
 	url := fmt.Sprintf("http://%s/cgi-bin/luci/;stok=%s/%s", c.Host, c.Token, apiPath)
 
 	logger.Debug("发送GET请求: %s", url)
@@ -36,7 +58,12 @@ func (c *BaseRouterClient) Get(apiPath string) ([]byte, error) {
 	client := &http.Client{
 		Timeout: 30 * time.Second, // 增加超时时间到30秒
 	}
-	resp, err := client.Get(url)
+	req, err := http.NewRequestWithContext(ctx, "GET", url, nil)
+	if err != nil {
+		logger.Debug("创建GET请求失败: %v", err)
+		return nil, err
+	}
+	resp, err := client.Do(req)
 	if err != nil {
 		logger.Debug("GET请求失败: %v", err)
 		return nil, err
@@ -54,13 +81,13 @@ func (c *BaseRouterClient) Get(apiPath string) ([]byte, error) {
 }
 
 // HTTP POST请求
-func (c *BaseRouterClient) Post(apiPath string, data string) ([]byte, error) {
+func (c *BaseRouterClient) Post(ctx context.Context, apiPath string, data string) ([]byte, error) {
 	url := fmt.Sprintf("http://%s/cgi-bin/luci/;stok=%s/%s", c.Host, c.Token, apiPath)
 
 	logger.Debug("发送POST请求: %s", url)
 	logger.Debug("POST请求数据: %s", data)
 
-	req, err := http.NewRequest("POST", url, bytes.NewBufferString(data))
+	req, err := http.NewRequestWithContext(ctx, "POST", url, bytes.NewBufferString(data))
 	if err != nil {
 		logger.Debug("创建POST请求失败: %v", err)
 		return nil, err
@@ -89,12 +116,13 @@ func (c *BaseRouterClient) Post(apiPath string, data string) ([]byte, error) {
 }
 
 // CheckPortOpen 检查指定端口是否开放
-func (c *BaseRouterClient) CheckPortOpen(port int) bool {
-	address := fmt.Sprintf("%s:%d", c.Host, port)
+func (c *BaseRouterClient) CheckPortOpen(ctx context.Context, port int) bool {
+	address := net.JoinHostPort(c.Host, strconv.Itoa(port))
 	logger.Debug("检查端口是否开放: %s", address)
 
 	// 设置较短的超时时间，避免长时间等待
-	conn, err := net.DialTimeout("tcp", address, 3*time.Second)
+	dialer := &net.Dialer{Timeout: 3 * time.Second}
+	conn, err := dialer.DialContext(ctx, "tcp", address)
 	if err != nil {
 		logger.Debug("端口 %d 未开放: %v", port, err)
 		return false
@@ -106,14 +134,14 @@ func (c *BaseRouterClient) CheckPortOpen(port int) bool {
 }
 
 // SetSystemTime 设置系统时间 (通用实现)
-func (c *BaseRouterClient) SetSystemTime() error {
+func (c *BaseRouterClient) SetSystemTime(ctx context.Context) error {
 	logger.Info("设置系统时间...")
 
 	now := time.Now()
 	timeStr := now.Format("2006-1-2 15:4:5")
 
 	apiPath := fmt.Sprintf("api/misystem/set_sys_time?time=%s&timezone=CST-8", timeStr)
-	respBody, err := c.Get(apiPath)
+	respBody, err := c.Get(ctx, apiPath)
 	if err != nil {
 		return fmt.Errorf("设置系统时间失败: %v", err)
 	}
@@ -129,6 +157,129 @@ func (c *BaseRouterClient) SetSystemTime() error {
 	return nil
 }
 
+// Reboot 通过API重启路由器
+func (c *BaseRouterClient) Reboot(ctx context.Context) error {
+	logger.Info("重启路由器...")
+
+	respBody, err := c.Get(ctx, "api/xqsystem/reboot?client=web")
+	if err != nil {
+		return fmt.Errorf("重启路由器失败: %v", err)
+	}
+
+	responseStr := string(respBody)
+	if !strings.Contains(responseStr, `"code":0`) {
+		return fmt.Errorf("重启路由器失败，响应: %s", responseStr)
+	}
+
+	logger.Info("已发送重启指令")
+	return nil
+}
+
+// WaitForReboot 等待路由器重启完成
+// 先等待管理页面不可访问，再等待其重新可用，整个过程不超过timeout
+func WaitForReboot(ctx context.Context, host string, timeout time.Duration) error {
+	deadline := time.Now().Add(timeout)
+	client := &http.Client{Timeout: 5 * time.Second}
+	url := fmt.Sprintf("http://%s/cgi-bin/luci/api/xqsystem/init_info", host)
+
+	// 管理页面是否可用
+	alive := func() bool {
+		req, err := http.NewRequestWithContext(ctx, "GET", url, nil)
+		if err != nil {
+			return false
+		}
+		resp, err := client.Do(req)
+		if err != nil {
+			return false
+		}
+		defer resp.Body.Close()
+		body, err := io.ReadAll(resp.Body)
+		return err == nil && resp.StatusCode == http.StatusOK && strings.Contains(string(body), `"code":0`)
+	}
+
+	logger.Info("等待路由器关闭...")
+	for alive() {
+		if time.Now().After(deadline) {
+			return fmt.Errorf("路由器在 %v 内没有重启", timeout)
+		}
+		if err := sleepContext(ctx, 3*time.Second); err != nil {
+			return err
+		}
+	}
+
+	logger.Info("等待路由器管理页面恢复...")
+	for !alive() {
+		if time.Now().After(deadline) {
+			return fmt.Errorf("路由器在 %v 内没有恢复", timeout)
+		}
+		if err := sleepContext(ctx, 5*time.Second); err != nil {
+			return err
+		}
+	}
+
+	logger.Info("路由器管理页面已恢复")
+	return nil
+}
+
+// GetSerialNumber 从状态接口读取路由器的序列号
+func (c *BaseRouterClient) GetSerialNumber(ctx context.Context) (string, error) {
+	body, err := c.Get(ctx, "api/misystem/status")
+	if err != nil {
+		return "", fmt.Errorf("读取路由器状态失败: %v", err)
+	}
+
+	var resp struct {
+		Code     int    `json:"code"`
+		Msg      string `json:"msg"`
+		Hardware struct {
+			SN string `json:"sn"`
+		} `json:"hardware"`
+	}
+	if err := json.Unmarshal(body, &resp); err != nil {
+		logger.Debug("解析响应失败: %v, 原始响应: %s", err, string(body))
+		return "", fmt.Errorf("解析路由器状态失败: %v", err)
+	}
+	if resp.Code != 0 {
+		return "", fmt.Errorf("API错误: %s (代码: %d)", resp.Msg, resp.Code)
+	}
+
+	sn := strings.TrimSpace(resp.Hardware.SN)
+	if sn == "" {
+		return "", fmt.Errorf("路由器状态中没有序列号")
+	}
+	return sn, nil
+}
+
+// Logout 注销登录，使token失效
+func (c *BaseRouterClient) Logout(ctx context.Context) error {
+	if c.Token == "" {
+		return nil
+	}
+	if _, err := c.Get(ctx, "web/logout"); err != nil {
+		return fmt.Errorf("注销登录失败: %v", err)
+	}
+	logger.Debug("已注销登录")
+	c.Token = ""
+	return nil
+}
+
+// Cleanup 退出前清理各命令通道在路由器上留下的痕迹并注销登录，尽力而为，单个失败不影响其余清理
+func (c *BaseRouterClient) Cleanup(ctx context.Context) error {
+	var errs []string
+	for _, ch := range c.channels {
+		if err := ch.Cleanup(ctx); err != nil {
+			errs = append(errs, fmt.Sprintf("%s: %v", ch.Name(), err))
+		}
+	}
+	if err := c.Logout(ctx); err != nil {
+		errs = append(errs, err.Error())
+	}
+	if len(errs) > 0 {
+		return fmt.Errorf("清理失败: %s", strings.Join(errs, "; "))
+	}
+	return nil
+}
+
 // GetSSHCommand 获取适用于此型号的SSH连接命令 (基本实现，子类可覆写)
 func (c *BaseRouterClient) GetSSHCommand() string {
 	// 默认的SSH连接命令
@@ -141,64 +292,161 @@ func (c *BaseRouterClient) GetTelnetCommand() string {
 	return fmt.Sprintf("telnet %s", c.Host)
 }
 
-// ExecuteCustomCommand 执行自定义命令 (需要子类实现)
-func (c *BaseRouterClient) ExecuteCustomCommand(command string) error {
-	return fmt.Errorf("此路由器型号不支持执行自定义命令")
+// useChannels 设置型号支持的命令通道及尝试顺序
+func (c *BaseRouterClient) useChannels(names ...string) {
+	c.channels = newChannels(c, names)
+}
+
+// pinChannel 固定使用指定的命令通道，如配方中声明的通道
+func (c *BaseRouterClient) pinChannel(name string) error {
+	for _, ch := range c.channels {
+		if ch.Name() == name {
+			c.active = ch
+			return nil
+		}
+	}
+	return fmt.Errorf("%s 不支持命令通道 %s", c.Model, name)
+}
+
+// ExecuteCustomCommand 通过型号支持的命令通道执行自定义命令
+// 尚未确定可用通道时按顺序尝试，第一个成功的通道会被后续命令沿用
+func (c *BaseRouterClient) ExecuteCustomCommand(ctx context.Context, command string) error {
+	if len(c.channels) == 0 {
+		return fmt.Errorf("此路由器型号不支持执行自定义命令")
+	}
+	logger.Info("准备执行命令: %s", command)
+
+	if c.active != nil {
+		if err := c.active.Execute(ctx, command); err != nil {
+			return err
+		}
+		logger.Info("命令执行成功")
+		return nil
+	}
+
+	var errs []string
+	for _, ch := range c.channels {
+		err := ch.Execute(ctx, command)
+		if err == nil {
+			logger.Debug("使用命令通道: %s", ch.Name())
+			c.active = ch
+			logger.Info("命令执行成功")
+			return nil
+		}
+		if ctx.Err() != nil {
+			return err
+		}
+		logger.Debug("命令通道 %s 不可用: %v", ch.Name(), err)
+		errs = append(errs, fmt.Sprintf("%s: %v", ch.Name(), err))
+	}
+	return fmt.Errorf("所有命令通道均不可用 (%s)", strings.Join(errs, "; "))
+}
+
+// ReadCommandOutput 执行命令并返回其输出
+func (c *BaseRouterClient) ReadCommandOutput(ctx context.Context, command string) (string, error) {
+	return c.readCommandOutput(ctx, c.ExecuteCustomCommand, command)
 }
 
 // EnableSSH 启用SSH (需要子类实现)
-func (c *BaseRouterClient) EnableSSH() error {
-	return fmt.Errorf("此路由器型号不支持启用SSH")
+func (c *BaseRouterClient) EnableSSH(ctx context.Context, services []Service) (*ShellResult, error) {
+	return nil, fmt.Errorf("此路由器型号不支持启用SSH")
 }
 
 // DisableSSH 关闭SSH (需要子类实现)
-func (c *BaseRouterClient) DisableSSH() error {
-	return fmt.Errorf("此路由器型号不支持关闭SSH")
+func (c *BaseRouterClient) DisableSSH(ctx context.Context, services []Service) (*ShellResult, error) {
+	return nil, fmt.Errorf("此路由器型号不支持关闭SSH")
+}
+
+// UseRecipe 使用用户提供的配方 (需要子类实现)
+func (c *BaseRouterClient) UseRecipe(recipe *Recipe) error {
+	return fmt.Errorf("此路由器型号不支持自定义配方")
+}
+
+// InstallAuthorizedKeys 写入SSH公钥 (需要子类实现)
+func (c *BaseRouterClient) InstallAuthorizedKeys(ctx context.Context, keys []string) error {
+	return fmt.Errorf("此路由器型号不支持写入SSH公钥")
+}
+
+// SetRootPassword 设置root密码 (需要子类实现)
+func (c *BaseRouterClient) SetRootPassword(ctx context.Context, password string) error {
+	return fmt.Errorf("此路由器型号不支持设置root密码")
+}
+
+// InstallPersistence 安装SSH持久化启动脚本 (需要子类实现)
+func (c *BaseRouterClient) InstallPersistence(ctx context.Context, services []Service) error {
+	return fmt.Errorf("此路由器型号不支持SSH持久化")
+}
+
+// RemovePersistence 移除SSH持久化启动脚本 (需要子类实现)
+func (c *BaseRouterClient) RemovePersistence(ctx context.Context) error {
+	return fmt.Errorf("此路由器型号不支持SSH持久化")
+}
+
+// ResumeShell 继续执行中断的操作 (需要子类实现)
+func (c *BaseRouterClient) ResumeShell(ctx context.Context) (*ShellResult, error) {
+	return nil, fmt.Errorf("此路由器型号不支持继续执行中断的操作")
+}
+
+// EnableMeshNode 在Mesh子节点上启用服务 (需要子类实现)
+func (c *BaseRouterClient) EnableMeshNode(ctx context.Context, node MeshNode, services []Service) (*ShellStatusResult, string, error) {
+	return nil, "", fmt.Errorf("此路由器型号不支持Mesh子节点")
+}
+
+// CheckMeshNodeStatus 检查Mesh子节点的服务状态 (需要子类实现)
+func (c *BaseRouterClient) CheckMeshNodeStatus(ctx context.Context, node MeshNode, services []Service) (*ShellStatusResult, string, error) {
+	return nil, "", fmt.Errorf("此路由器型号不支持Mesh子节点")
 }
 
 // VerifySSHStatus 验证SSH状态 (需要子类实现)
-func (c *BaseRouterClient) VerifySSHStatus() (bool, error) {
+func (c *BaseRouterClient) VerifySSHStatus(ctx context.Context) (bool, error) {
 	return false, fmt.Errorf("此路由器型号不支持验证SSH状态")
 }
 
 // CheckShellStatus 检查SSH和Telnet状态 (基本实现，子类可覆写)
-func (c *BaseRouterClient) CheckShellStatus() (bool, string, error) {
-	logger.Info("检查SSH和Telnet状态...")
+func (c *BaseRouterClient) CheckShellStatus(ctx context.Context, services []Service) (*ShellStatusResult, string, error) {
+	logger.Info("检查%s状态...", ServiceNames(services))
 	
 	// 创建结果结构体
-	result := &ShellStatusResult{
-		SSHEnabled:     false,
-		TelnetEnabled:  false,
-		SSHPortOpen:    false,
-		TelnetPortOpen: false,
-	}
-	
-	// 检查SSH端口(22)是否开放
-	logger.Info("检查SSH端口(22)是否开放...")
-	result.SSHPortOpen = c.CheckPortOpen(22)
+	result := &ShellStatusResult{}
 	
-	// 检查Telnet端口(23)是否开放
-	logger.Info("检查Telnet端口(23)是否开放...")
-	result.TelnetPortOpen = c.CheckPortOpen(23)
+	// 检查各服务端口是否开放
+	for _, service := range services {
+		logger.Info("检查%s端口(%d)是否开放...", service.DisplayName(), service.Port())
+		open := c.CheckPortOpen(ctx, service.Port())
+
+		// 基本实现无法读取配置，以端口状态为准，子类可以覆写此方法以提供更详细的状态检查
+		switch service {
+		case ServiceSSH:
+			result.SSHPortOpen, result.SSHEnabled = open, open
+		case ServiceTelnet:
+			result.TelnetPortOpen, result.TelnetEnabled = open, open
+		}
+	}
 	
-	// 生成详细状态报告
+	return result, FormatShellStatus(result, services, c.GetSSHCommand(), c.GetTelnetCommand()), nil
+}
+
+// FormatShellStatus 生成按服务分组的详细状态报告
+func FormatShellStatus(result *ShellStatusResult, services []Service, sshCommand, telnetCommand string) string {
 	var detailsBuilder strings.Builder
-	detailsBuilder.WriteString("SSH状态:\n")
-	detailsBuilder.WriteString(fmt.Sprintf("  - 端口22开放: %v\n", result.SSHPortOpen))
-	detailsBuilder.WriteString("\nTelnet状态:\n")
-	detailsBuilder.WriteString(fmt.Sprintf("  - 端口23开放: %v\n", result.TelnetPortOpen))
-	
-	// 如果端口开放，显示连接命令
+	for i, service := range services {
+		if i > 0 {
+			detailsBuilder.WriteString("\n")
+		}
+		detailsBuilder.WriteString(fmt.Sprintf("%s状态:\n", service.DisplayName()))
+		detailsBuilder.WriteString(fmt.Sprintf("  - 配置中启用: %v\n", result.Enabled(service)))
+		detailsBuilder.WriteString(fmt.Sprintf("  - 端口%d开放: %v\n", service.Port(), result.PortOpen(service)))
+		detailsBuilder.WriteString(fmt.Sprintf("  - 总体状态: %s\n", result.Summary(service)))
+	}
+
+	// 如果服务可以访问，显示连接命令
 	detailsBuilder.WriteString("\n连接信息:\n")
-	if result.SSHPortOpen {
-		detailsBuilder.WriteString(fmt.Sprintf("  - SSH连接命令: %s\n", c.GetSSHCommand()))
+	if containsService(services, ServiceSSH) && result.Ready(ServiceSSH) {
+		detailsBuilder.WriteString(fmt.Sprintf("  - SSH连接命令: %s\n", sshCommand))
 	}
-	if result.TelnetPortOpen {
-		detailsBuilder.WriteString(fmt.Sprintf("  - Telnet连接命令: %s\n", c.GetTelnetCommand()))
+	if containsService(services, ServiceTelnet) && result.Ready(ServiceTelnet) {
+		detailsBuilder.WriteString(fmt.Sprintf("  - Telnet连接命令: %s\n", telnetCommand))
 	}
-	
-	// 基本实现只检查端口，子类可以覆写此方法以提供更详细的状态检查
-	overallStatus := result.SSHPortOpen || result.TelnetPortOpen
-	
-	return overallStatus, detailsBuilder.String(), nil
-}
\ No newline at end of file
+
+	return detailsBuilder.String()
+}
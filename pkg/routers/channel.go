@@ -0,0 +1,57 @@
+package routers
+
+import (
+	"context"
+	"fmt"
+	"sort"
+)
+
+// CommandChannel 在路由器上执行命令的通道，通常是某个接口的命令注入
+// 许多型号共用同一个通道，型号只需声明支持哪些通道以及尝试的顺序
+type CommandChannel interface {
+	// Name 通道名称，与配方中的 channel 对应
+	Name() string
+
+	// Execute 执行一条命令，命令不能包含双引号和反斜杠
+	Execute(ctx context.Context, command string) error
+
+	// Cleanup 删除执行命令时在路由器上留下的痕迹
+	Cleanup(ctx context.Context) error
+}
+
+// channelFactory 为已登录的客户端创建通道
+type channelFactory func(client *BaseRouterClient) CommandChannel
+
+// 已注册的命令通道
+var channelRegistry = map[string]channelFactory{}
+
+// RegisterChannel 注册命令通道，名称重复属于程序错误
+func RegisterChannel(name string, factory channelFactory) {
+	if _, ok := channelRegistry[name]; ok {
+		panic(fmt.Sprintf("routers: 命令通道 %q 重复注册", name))
+	}
+	channelRegistry[name] = factory
+}
+
+// ChannelNames 返回所有已注册的命令通道名称
+func ChannelNames() []string {
+	names := make([]string, 0, len(channelRegistry))
+	for name := range channelRegistry {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// newChannels 按顺序创建命令通道，通道未注册属于程序错误
+func newChannels(client *BaseRouterClient, names []string) []CommandChannel {
+	channels := make([]CommandChannel, 0, len(names))
+	for _, name := range names {
+		factory, ok := channelRegistry[name]
+		if !ok {
+			panic(fmt.Sprintf("routers: 未注册的命令通道 %q", name))
+		}
+		channels = append(channels, factory(client))
+	}
+	return channels
+}
@@ -0,0 +1,88 @@
+package routers
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/bamzest/xiaomi-router-shell-enabler/pkg/auth"
+	"github.com/bamzest/xiaomi-router-shell-enabler/pkg/logger"
+)
+
+// RouterInfo 无需登录即可从 init_info 接口获取的路由器信息
+type RouterInfo struct {
+	Code           int    `json:"code"`
+	Hardware       string `json:"hardware"`       // 硬件代号，如 RB06
+	RomVersion     string `json:"romversion"`     // 固件版本
+	Model          string `json:"model"`          // 如 xiaomi.router.rb06
+	DisplayName    string `json:"displayName"`    // 出厂名称
+	RouterName     string `json:"routername"`     // 用户设置的名称
+	CountryCode    string `json:"countrycode"`    // 固件地区
+	NewEncryptMode int    `json:"newEncryptMode"` // 1 表示登录使用SHA256
+	Inited         int    `json:"inited"`         // 是否已完成初始化设置
+}
+
+// HashMode 路由器登录使用的哈希算法
+func (i *RouterInfo) HashMode() auth.HashMode {
+	if i.NewEncryptMode == 1 {
+		return auth.HashSHA256
+	}
+	return auth.HashSHA1
+}
+
+// Description 路由器信息的简要描述
+func (i *RouterInfo) Description() string {
+	name := i.DisplayName
+	if name == "" {
+		name = i.Model
+	}
+	return fmt.Sprintf("%s (硬件: %s, 固件: %s %s)", name, i.Hardware, i.RomVersion, i.CountryCode)
+}
+
+// ProbeRouterInfo 读取路由器的 init_info，用于登录前识别型号和固件
+func ProbeRouterInfo(ctx context.Context, host string) (*RouterInfo, error) {
+	url := fmt.Sprintf("http://%s/cgi-bin/luci/api/xqsystem/init_info", host)
+	logger.Debug("读取路由器信息: %s", url)
+
+	req, err := http.NewRequestWithContext(ctx, "GET", url, nil)
+	if err != nil {
+		return nil, fmt.Errorf("创建请求失败: %v", err)
+	}
+
+	client := &http.Client{Timeout: 10 * time.Second}
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("读取路由器信息失败: %v", err)
+	}
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, fmt.Errorf("读取路由器信息失败: %v", err)
+	}
+	logger.Debug("路由器信息: %s", string(body))
+
+	var info RouterInfo
+	if err := json.Unmarshal(body, &info); err != nil {
+		return nil, fmt.Errorf("解析路由器信息失败: %v", err)
+	}
+	if info.Code != 0 {
+		return nil, fmt.Errorf("读取路由器信息失败，错误代码: %d", info.Code)
+	}
+	if info.Hardware == "" {
+		// 部分固件只在 model 中带有硬件代号，如 xiaomi.router.rb06
+		if idx := strings.LastIndex(info.Model, "."); idx >= 0 {
+			info.Hardware = strings.ToUpper(info.Model[idx+1:])
+		}
+	}
+	return &info, nil
+}
+
+// DetectModel 根据硬件代号查找已注册的型号
+func DetectModel(info *RouterInfo) (*ModelDescriptor, bool) {
+	return LookupHardware(info.Hardware)
+}
@@ -0,0 +1,135 @@
+package routers
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// FirmwareStatus 固件版本的兼容状态
+type FirmwareStatus string
+
+const (
+	FirmwareWorking  FirmwareStatus = "working"  // 已确认可以启用
+	FirmwarePatched  FirmwareStatus = "patched"  // 已确认漏洞被修补，无法启用
+	FirmwareUntested FirmwareStatus = "untested" // 没有记录
+	FirmwareUnknown  FirmwareStatus = "unknown"  // 无法读取固件版本
+)
+
+// DisplayName 状态的显示名称
+func (s FirmwareStatus) DisplayName() string {
+	switch s {
+	case FirmwareWorking:
+		return "已知可用"
+	case FirmwarePatched:
+		return "已知被修补"
+	case FirmwareUntested:
+		return "未测试"
+	default:
+		return "未知"
+	}
+}
+
+// FirmwareRange 一段固件版本及其兼容状态，Min/Max 为闭区间，为空表示不限
+type FirmwareRange struct {
+	Min    string         `json:"min,omitempty"`
+	Max    string         `json:"max,omitempty"`
+	Status FirmwareStatus `json:"status"`
+	Note   string         `json:"note,omitempty"` // 说明，如修补了哪个接口
+}
+
+// Contains 版本是否在范围内
+func (r FirmwareRange) Contains(version string) bool {
+	if r.Min != "" && CompareVersions(version, r.Min) < 0 {
+		return false
+	}
+	if r.Max != "" && CompareVersions(version, r.Max) > 0 {
+		return false
+	}
+	return true
+}
+
+// String 版本范围的显示形式
+func (r FirmwareRange) String() string {
+	switch {
+	case r.Min == "" && r.Max == "":
+		return "所有版本"
+	case r.Min == r.Max:
+		return r.Min
+	case r.Max == "":
+		return r.Min + " 及以上"
+	case r.Min == "":
+		return r.Max + " 及以下"
+	default:
+		return r.Min + " - " + r.Max
+	}
+}
+
+// FirmwareCheck 固件兼容性检查的结果
+type FirmwareCheck struct {
+	Version string         `json:"version"`
+	Status  FirmwareStatus `json:"status"`
+	Range   *FirmwareRange `json:"range,omitempty"` // 命中的范围，未测试或未知时为空
+}
+
+// Explain 检查结果的说明
+func (c *FirmwareCheck) Explain(d *ModelDescriptor) string {
+	switch c.Status {
+	case FirmwareUnknown:
+		return fmt.Sprintf("无法读取固件版本，无法确认 %s 的兼容性", d.DisplayName)
+	case FirmwareUntested:
+		return fmt.Sprintf("固件 %s 不在 %s 的兼容性记录中，尚未经过测试", c.Version, d.DisplayName)
+	}
+
+	msg := fmt.Sprintf("固件 %s 属于 %s 的%s版本 (%s)", c.Version, d.DisplayName, c.Status.DisplayName(), c.Range)
+	if c.Range.Note != "" {
+		msg += ": " + c.Range.Note
+	}
+	return msg
+}
+
+// CheckFirmware 对照型号的兼容性记录检查固件版本，按声明顺序取第一个命中的范围
+func (d *ModelDescriptor) CheckFirmware(version string) *FirmwareCheck {
+	version = strings.TrimSpace(version)
+	if version == "" {
+		return &FirmwareCheck{Status: FirmwareUnknown}
+	}
+	for i := range d.Firmware {
+		if d.Firmware[i].Contains(version) {
+			return &FirmwareCheck{Version: version, Status: d.Firmware[i].Status, Range: &d.Firmware[i]}
+		}
+	}
+	return &FirmwareCheck{Version: version, Status: FirmwareUntested}
+}
+
+// CompareVersions 按数字逐段比较点分隔的版本号，如 1.0.9 < 1.0.26
+// 无法解析为数字的段按字符串比较，缺少的段视为0
+func CompareVersions(a, b string) int {
+	pa := strings.Split(strings.TrimSpace(a), ".")
+	pb := strings.Split(strings.TrimSpace(b), ".")
+	for i := 0; i < len(pa) || i < len(pb); i++ {
+		sa, sb := "0", "0"
+		if i < len(pa) {
+			sa = pa[i]
+		}
+		if i < len(pb) {
+			sb = pb[i]
+		}
+
+		na, errA := strconv.Atoi(sa)
+		nb, errB := strconv.Atoi(sb)
+		if errA == nil && errB == nil {
+			if na != nb {
+				if na < nb {
+					return -1
+				}
+				return 1
+			}
+			continue
+		}
+		if c := strings.Compare(sa, sb); c != 0 {
+			return c
+		}
+	}
+	return 0
+}
@@ -0,0 +1,49 @@
+package routers
+
+import "testing"
+
+func TestCompareVersions(t *testing.T) {
+	for _, tc := range []struct {
+		a, b string
+		want int
+	}{
+		{"1.0.9", "1.0.26", -1},
+		{"1.0.26", "1.0.9", 1},
+		{"1.0.17", "1.0.17", 0},
+		{"1.0", "1.0.0", 0},
+		{"1.1.0", "1.0.99", 1},
+	} {
+		if got := CompareVersions(tc.a, tc.b); got != tc.want {
+			t.Errorf("CompareVersions(%q, %q) = %d，期望 %d", tc.a, tc.b, got, tc.want)
+		}
+	}
+}
+
+func TestCheckFirmware(t *testing.T) {
+	d := &ModelDescriptor{
+		DisplayName: "测试型号",
+		Firmware: []FirmwareRange{
+			{Max: "1.0.17", Status: FirmwareWorking},
+			{Min: "1.1.0", Status: FirmwarePatched, Note: "接口已修补"},
+		},
+	}
+	for _, tc := range []struct {
+		version string
+		want    FirmwareStatus
+	}{
+		{"", FirmwareUnknown},
+		{"1.0.9", FirmwareWorking},
+		{"1.0.17", FirmwareWorking},
+		{"1.0.26", FirmwareUntested},
+		{"1.1.0", FirmwarePatched},
+		{"1.2.7", FirmwarePatched},
+	} {
+		check := d.CheckFirmware(tc.version)
+		if check.Status != tc.want {
+			t.Errorf("CheckFirmware(%q) = %s，期望 %s", tc.version, check.Status, tc.want)
+		}
+		if check.Explain(d) == "" {
+			t.Errorf("CheckFirmware(%q) 没有说明", tc.version)
+		}
+	}
+}
@@ -0,0 +1,63 @@
+package routers
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/bamzest/xiaomi-router-shell-enabler/pkg/logger"
+)
+
+// 通过 IoT 设备配网接口执行命令，ssid 参数会被未经转义地传给shell
+const ChannelIoTDev = "iotdev"
+
+func init() {
+	RegisterChannel(ChannelIoTDev, func(client *BaseRouterClient) CommandChannel {
+		return &iotDevChannel{client: client}
+	})
+}
+
+// iotDevChannel set_config_iotdev 命令通道，AX3600、AX6000 等型号的旧固件可用
+type iotDevChannel struct {
+	client *BaseRouterClient
+}
+
+// Name 通道名称
+func (ch *iotDevChannel) Name() string {
+	return ChannelIoTDev
+}
+
+// Execute 将命令以换行分隔放入 ssid 参数，接口调用配网程序时命令会被shell执行
+func (ch *iotDevChannel) Execute(ctx context.Context, command string) error {
+	ssid := fmt.Sprintf("-h\n%s\n", command)
+	// QueryEscape 将空格编码为+，LuCI 不会将其还原为空格
+	encoded := strings.ReplaceAll(url.QueryEscape(ssid), "+", "%20")
+	apiPath := fmt.Sprintf("api/misystem/set_config_iotdev?bssid=Xiaomi&user_id=longdike&ssid=%s", encoded)
+
+	logger.Debug("通过IoT配网接口执行命令")
+
+	respBody, err := ch.client.Get(ctx, apiPath)
+	if err != nil {
+		return fmt.Errorf("IoT配网接口请求失败: %v", err)
+	}
+
+	var resp APIResponse
+	if err := json.Unmarshal(respBody, &resp); err != nil {
+		logger.Debug("解析响应失败: %v, 原始响应: %s", err, string(respBody))
+		return fmt.Errorf("解析响应失败: %v", err)
+	}
+	if resp.Code != 0 {
+		return fmt.Errorf("API错误: %s (代码: %d)", resp.Msg, resp.Code)
+	}
+
+	// 等待一秒，确保命令执行
+	return sleepContext(ctx, 1*time.Second)
+}
+
+// Cleanup 此通道不会在路由器上留下任务，无需清理
+func (ch *iotDevChannel) Cleanup(ctx context.Context) error {
+	return nil
+}
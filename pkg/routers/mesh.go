@@ -0,0 +1,191 @@
+package routers
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"text/template"
+
+	"github.com/bamzest/xiaomi-router-shell-enabler/pkg/logger"
+)
+
+// MeshNode Mesh网络中的一个路由器节点
+type MeshNode struct {
+	Name     string `json:"name"`
+	IP       string `json:"ip"`
+	MAC      string `json:"mac"`
+	Hardware string `json:"hardware"`
+	Primary  bool   `json:"primary"` // 是否为主路由
+}
+
+// Description 节点的简要描述
+func (n MeshNode) Description() string {
+	role := "子节点"
+	if n.Primary {
+		role = "主路由"
+	}
+	return fmt.Sprintf("%s %s (%s, 硬件: %s)", role, n.Name, n.IP, n.Hardware)
+}
+
+// topoNode topo_graph 接口返回的拓扑节点，leafs 中既有Mesh子节点也有普通终端
+type topoNode struct {
+	Name     string     `json:"name"`
+	IP       string     `json:"ip"`
+	MAC      string     `json:"mac"`
+	Hardware string     `json:"hardware"`
+	Leafs    []topoNode `json:"leafs"`
+}
+
+// ListMeshNodes 从主路由的拓扑接口读取Mesh节点，第一个为主路由
+func (c *BaseRouterClient) ListMeshNodes(ctx context.Context) ([]MeshNode, error) {
+	body, err := c.Get(ctx, "api/misystem/topo_graph")
+	if err != nil {
+		return nil, fmt.Errorf("读取Mesh拓扑失败: %v", err)
+	}
+
+	var resp struct {
+		Code  int      `json:"code"`
+		Msg   string   `json:"msg"`
+		Graph topoNode `json:"graph"`
+	}
+	if err := json.Unmarshal(body, &resp); err != nil {
+		logger.Debug("解析响应失败: %v, 原始响应: %s", err, string(body))
+		return nil, fmt.Errorf("解析Mesh拓扑失败: %v", err)
+	}
+	if resp.Code != 0 {
+		return nil, fmt.Errorf("API错误: %s (代码: %d)", resp.Msg, resp.Code)
+	}
+
+	primary := MeshNode{
+		Name:     resp.Graph.Name,
+		IP:       resp.Graph.IP,
+		MAC:      resp.Graph.MAC,
+		Hardware: resp.Graph.Hardware,
+		Primary:  true,
+	}
+	if primary.IP == "" {
+		primary.IP = c.Host
+	}
+
+	nodes := []MeshNode{primary}
+	var walk func(leafs []topoNode)
+	walk = func(leafs []topoNode) {
+		for _, leaf := range leafs {
+			// 只有路由器节点带有硬件代号，普通终端没有
+			if leaf.Hardware != "" && leaf.IP != "" {
+				nodes = append(nodes, MeshNode{Name: leaf.Name, IP: leaf.IP, MAC: leaf.MAC, Hardware: leaf.Hardware})
+			}
+			walk(leaf.Leafs)
+		}
+	}
+	walk(resp.Graph.Leafs)
+
+	logger.Debug("Mesh节点: %v", nodes)
+	return nodes, nil
+}
+
+// renderMeshRelay 生成在主路由上转发到子节点执行的命令
+func renderMeshRelay(relay, ip, command string) (string, error) {
+	tmpl, err := template.New("mesh_relay").Option("missingkey=error").Parse(relay)
+	if err != nil {
+		return "", fmt.Errorf("解析 mesh_relay 失败: %v", err)
+	}
+
+	var buf bytes.Buffer
+	data := struct{ IP, Command string }{ip, command}
+	if err := tmpl.Execute(&buf, data); err != nil {
+		return "", fmt.Errorf("无法生成转发命令: %v", err)
+	}
+	return buf.String(), nil
+}
+
+// meshNVRAMKey 服务在nvram中的开关
+func meshNVRAMKey(service Service) string {
+	return string(service) + "_en"
+}
+
+// relayCommand 生成转发到子节点执行的命令，命令会被放入单引号中，不能再包含单引号
+func (c *recipeClient) relayCommand(node MeshNode, command string) (string, error) {
+	if c.recipe.MeshRelay == "" {
+		return "", fmt.Errorf("配方 %s 没有设置 mesh_relay，无法在Mesh子节点上执行命令", c.recipe.Source)
+	}
+	if strings.Contains(command, "'") {
+		return "", fmt.Errorf("转发到子节点的命令不能包含单引号: %s", command)
+	}
+	return renderMeshRelay(c.recipe.MeshRelay, node.IP, command)
+}
+
+// meshExecutor 通过主路由在子节点上执行命令
+func (c *recipeClient) meshExecutor(node MeshNode) commandExecutor {
+	return func(ctx context.Context, command string) error {
+		relayed, err := c.relayCommand(node, command)
+		if err != nil {
+			return err
+		}
+		return c.ExecuteCustomCommand(ctx, relayed)
+	}
+}
+
+// meshProber 通过主路由在子节点上执行命令并读回输出
+func (c *recipeClient) meshProber(node MeshNode) commandProber {
+	return func(ctx context.Context, command string) (string, error) {
+		relayed, err := c.relayCommand(node, command)
+		if err != nil {
+			return "", err
+		}
+		return c.ReadCommandOutput(ctx, relayed)
+	}
+}
+
+// EnableMeshNode 通过主路由在子节点上执行与主路由相同的启用步骤，失败或被中断时回滚
+func (c *recipeClient) EnableMeshNode(ctx context.Context, node MeshNode, services []Service) (*ShellStatusResult, string, error) {
+	logger.Info("在%s上启用%s...", node.Description(), ServiceNames(services))
+
+	if _, err := c.relayCommand(node, "true"); err != nil {
+		return nil, "", err
+	}
+	exec, probe := c.meshExecutor(node), c.meshProber(node)
+
+	prior, err := c.capturePriorState(ctx, probe)
+	if err != nil {
+		return nil, "", fmt.Errorf("记录 %s 操作前的状态失败: %v", node.IP, err)
+	}
+	logger.Debug("%s 操作前的状态: %v", node.IP, prior)
+
+	tx, err := newTransaction(exec, probe, c.recipe.steps("enable", services), prior, c.recipe.RollbackCommit)
+	if err != nil {
+		return nil, "", err
+	}
+	if err := tx.run(ctx); err != nil {
+		return nil, "", err
+	}
+
+	return c.CheckMeshNodeStatus(ctx, node, services)
+}
+
+// CheckMeshNodeStatus 通过主路由读取子节点的nvram，并直接检查子节点的端口
+func (c *recipeClient) CheckMeshNodeStatus(ctx context.Context, node MeshNode, services []Service) (*ShellStatusResult, string, error) {
+	probe := c.meshProber(node)
+	nodeClient := &BaseRouterClient{Host: node.IP}
+
+	status := &ShellStatusResult{}
+	for _, service := range services {
+		value, err := probe(ctx, "nvram get "+meshNVRAMKey(service))
+		if err != nil {
+			return nil, "", fmt.Errorf("读取 %s 的%s配置失败: %v", node.IP, service.DisplayName(), err)
+		}
+		enabled := value == "1"
+		open := nodeClient.CheckPortOpen(ctx, service.Port())
+
+		switch service {
+		case ServiceSSH:
+			status.SSHEnabled, status.SSHPortOpen = enabled, open
+		case ServiceTelnet:
+			status.TelnetEnabled, status.TelnetPortOpen = enabled, open
+		}
+	}
+
+	return status, FormatShellStatus(status, services, c.sshCommand(node.IP), fmt.Sprintf("telnet %s", node.IP)), nil
+}
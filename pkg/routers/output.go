@@ -0,0 +1,82 @@
+package routers
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/bamzest/xiaomi-router-shell-enabler/pkg/logger"
+)
+
+// 命令输出的临时目录，小米固件的nginx将其以 /backup/log/ 对外提供，无需登录即可读取
+const (
+	outputDir     = "/tmp/syslogbackup"
+	outputURLPath = "backup/log"
+)
+
+// commandProber 在路由器上执行命令并返回其输出
+type commandProber func(ctx context.Context, command string) (string, error)
+
+// readCommandOutput 执行命令，将输出写入临时文件后通过HTTP读回，读取后删除临时文件
+// 命令通道本身无法返回输出，只能借助可以通过网页访问的目录
+func (c *BaseRouterClient) readCommandOutput(ctx context.Context, exec commandExecutor, command string) (string, error) {
+	token, err := randomToken()
+	if err != nil {
+		return "", err
+	}
+	file := token + ".txt"
+
+	wrapped := fmt.Sprintf("mkdir -p %s && (%s) > %s/%s 2>&1", outputDir, command, outputDir, file)
+	if err := exec(ctx, wrapped); err != nil {
+		return "", err
+	}
+	defer func() {
+		// 即使ctx已被取消也要删除临时文件
+		cleanupCtx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+		defer cancel()
+		if err := exec(cleanupCtx, fmt.Sprintf("rm -f %s/%s", outputDir, file)); err != nil {
+			logger.Debug("删除临时输出文件失败: %v", err)
+		}
+	}()
+
+	url := fmt.Sprintf("http://%s/%s/%s", c.Host, outputURLPath, file)
+	logger.Debug("读取命令输出: %s", url)
+
+	client := &http.Client{Timeout: 10 * time.Second}
+	req, err := http.NewRequestWithContext(ctx, "GET", url, nil)
+	if err != nil {
+		return "", fmt.Errorf("创建请求失败: %v", err)
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return "", fmt.Errorf("读取命令输出失败: %v", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("读取命令输出失败: HTTP %d", resp.StatusCode)
+	}
+
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return "", fmt.Errorf("读取命令输出失败: %v", err)
+	}
+
+	output := strings.TrimSpace(string(body))
+	logger.Debug("命令输出: %s", output)
+	return output, nil
+}
+
+// randomToken 生成随机文件名
+func randomToken() (string, error) {
+	buf := make([]byte, 8)
+	if _, err := rand.Read(buf); err != nil {
+		return "", fmt.Errorf("生成随机文件名失败: %v", err)
+	}
+	return hex.EncodeToString(buf), nil
+}
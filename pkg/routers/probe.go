@@ -0,0 +1,82 @@
+package routers
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/bamzest/xiaomi-router-shell-enabler/pkg/logger"
+)
+
+// ChannelProbeResult 一个命令通道的探测结果
+type ChannelProbeResult struct {
+	Channel  string
+	Accepted bool  // 接口接受了请求
+	Executed bool  // 标记命令确实被执行
+	Err      error // 失败原因
+}
+
+// Summary 探测结果的描述
+func (r ChannelProbeResult) Summary() string {
+	switch {
+	case r.Executed:
+		return "可以执行命令"
+	case r.Accepted:
+		return fmt.Sprintf("接口接受了请求，但命令没有执行 (%v)", r.Err)
+	default:
+		return fmt.Sprintf("接口不可用 (%v)", r.Err)
+	}
+}
+
+// ProbeChannels 用无害的标记命令逐个探测已注册的命令通道
+// 标记命令只把随机字符串写入临时目录再通过HTTP读回，不会修改nvram或启动脚本，探测后删除临时文件和创建的任务
+func ProbeChannels(ctx context.Context, host, token string) []ChannelProbeResult {
+	base := &BaseRouterClient{Host: host, Token: token, Model: "probe"}
+
+	var results []ChannelProbeResult
+	for _, name := range ChannelNames() {
+		if ctx.Err() != nil {
+			break
+		}
+		ch := newChannels(base, []string{name})[0]
+		logger.Info("探测命令通道 %s...", name)
+		results = append(results, probeChannel(ctx, base, ch))
+
+		// 即使ctx已被取消也要删除创建的任务
+		cleanupCtx, cancel := context.WithTimeout(context.Background(), rollbackTimeout)
+		if err := ch.Cleanup(cleanupCtx); err != nil {
+			logger.Warn("清理命令通道 %s 失败: %v", name, err)
+		}
+		cancel()
+	}
+	return results
+}
+
+// probeChannel 通过通道执行 echo 标记，读回的输出与标记一致即说明通道可以执行命令
+func probeChannel(ctx context.Context, base *BaseRouterClient, ch CommandChannel) ChannelProbeResult {
+	result := ChannelProbeResult{Channel: ch.Name()}
+
+	marker, err := randomToken()
+	if err != nil {
+		result.Err = err
+		return result
+	}
+
+	exec := func(ctx context.Context, command string) error {
+		err := ch.Execute(ctx, command)
+		if err == nil {
+			result.Accepted = true
+		}
+		return err
+	}
+
+	output, err := base.readCommandOutput(ctx, exec, "echo "+marker)
+	switch {
+	case err != nil:
+		result.Err = err
+	case output != marker:
+		result.Err = fmt.Errorf("读回的内容与标记不一致: %q", output)
+	default:
+		result.Executed = true
+	}
+	return result
+}
@@ -0,0 +1,219 @@
+package routers
+
+import (
+	"embed"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+	"time"
+
+	"gopkg.in/yaml.v3"
+)
+
+// 内置配方，所有型号默认使用 default.yaml，流程不同的型号使用以型号名命名的配方
+//
+//go:embed recipes/*.yaml
+var builtinRecipes embed.FS
+
+// 所有型号共用的内置配方
+const defaultRecipe = "default"
+
+// Recipe 描述启用/关闭SSH和Telnet的完整流程
+type Recipe struct {
+	Model       string            `yaml:"model"` // 适用的型号，为空表示不限
+	Description string            `yaml:"description"`
+	Channel     string            `yaml:"channel"`    // 固定使用的命令通道，为空时按型号支持的通道依次尝试
+	StepDelay   time.Duration     `yaml:"step_delay"` // 每个步骤执行后的默认等待时间
+	Prior       map[string]string `yaml:"prior"`      // 操作前需要记录的值及读取命令，用于回滚
+	MeshRelay   string            `yaml:"mesh_relay"` // 在主路由上转发命令到Mesh子节点的命令模板，可引用 {{.IP}} 和 {{.Command}}
+
+	// 回滚时在所有撤销命令之后执行一次，把恢复的值写入flash，如 nvram commit
+	RollbackCommit string       `yaml:"rollback_commit"`
+	Enable         []RecipeStep `yaml:"enable"`
+	Disable        []RecipeStep `yaml:"disable"`
+
+	// 配方来源，用于错误信息
+	Source string `yaml:"-"`
+}
+
+// RecipeStep 配方中的一个步骤
+type RecipeStep struct {
+	Name          string        `yaml:"name"`
+	Services      []Service     `yaml:"services"`
+	Command       string        `yaml:"command"`
+	Undo          string        `yaml:"undo"`
+	Check         string        `yaml:"check"`
+	Expect        string        `yaml:"expect"`
+	OnlyIfChanged bool          `yaml:"only_if_changed"`
+	Delay         time.Duration `yaml:"delay"`
+}
+
+// BuiltinRecipe 返回指定型号的内置配方，没有该型号专用的配方时返回默认配方
+func BuiltinRecipe(model string) (*Recipe, error) {
+	for _, name := range []string{model, defaultRecipe} {
+		path := "recipes/" + name + ".yaml"
+		data, err := builtinRecipes.ReadFile(path)
+		if err != nil {
+			continue
+		}
+		return ParseRecipe(data, "内置配方 "+path)
+	}
+	return nil, fmt.Errorf("没有 %s 的内置配方", model)
+}
+
+// mustBuiltinRecipe 返回内置配方，内置配方有误属于程序错误
+func mustBuiltinRecipe(model string) *Recipe {
+	r, err := BuiltinRecipe(model)
+	if err != nil {
+		panic(err)
+	}
+	return r
+}
+
+// LoadRecipeFile 读取并校验用户提供的配方文件
+func LoadRecipeFile(path string) (*Recipe, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("读取配方文件失败: %v", err)
+	}
+	return ParseRecipe(data, path)
+}
+
+// ParseRecipe 解析并校验配方
+func ParseRecipe(data []byte, source string) (*Recipe, error) {
+	var r Recipe
+	if err := yaml.Unmarshal(data, &r); err != nil {
+		return nil, fmt.Errorf("解析配方 %s 失败: %v", source, err)
+	}
+	r.Source = source
+
+	if err := r.Validate(); err != nil {
+		return nil, err
+	}
+	return &r, nil
+}
+
+// Validate 校验配方，确保执行到一半时不会因为配方错误而失败
+func (r *Recipe) Validate() error {
+	fail := func(format string, args ...interface{}) error {
+		return fmt.Errorf("配方 %s 无效: %s", r.Source, fmt.Sprintf(format, args...))
+	}
+
+	if _, ok := channelRegistry[r.Channel]; r.Channel != "" && !ok {
+		return fail("不支持的命令通道 %q (可选: %s)", r.Channel, strings.Join(ChannelNames(), ", "))
+	}
+	if r.StepDelay < 0 {
+		return fail("step_delay 不能为负数")
+	}
+	if r.StepDelay == 0 {
+		r.StepDelay = stepDelay
+	}
+
+	if strings.ContainsAny(r.RollbackCommit, "\"\\\n") {
+		return fail("rollback_commit 不能包含双引号、反斜杠或换行")
+	}
+
+	if r.MeshRelay != "" {
+		if strings.ContainsAny(r.MeshRelay, "\"\\\n") {
+			return fail("mesh_relay 不能包含双引号、反斜杠或换行")
+		}
+		if _, err := renderMeshRelay(r.MeshRelay, "", ""); err != nil {
+			return fail("%v", err)
+		}
+	}
+
+	// 撤销命令只能引用 prior 中记录的值
+	dummy := make(map[string]string, len(r.Prior))
+	for key, command := range r.Prior {
+		if strings.TrimSpace(command) == "" {
+			return fail("prior.%s 缺少读取命令", key)
+		}
+		dummy[key] = ""
+	}
+
+	for _, section := range []struct {
+		name  string
+		steps []RecipeStep
+	}{
+		{"enable", r.Enable},
+		{"disable", r.Disable},
+	} {
+		if len(section.steps) == 0 {
+			return fail("%s 中没有步骤", section.name)
+		}
+
+		for i, step := range section.steps {
+			where := fmt.Sprintf("%s[%d]", section.name, i)
+			if step.Name == "" {
+				return fail("%s 缺少 name", where)
+			}
+			if strings.TrimSpace(step.Command) == "" {
+				return fail("%s (%s) 缺少 command", where, step.Name)
+			}
+			for _, s := range step.Services {
+				if !containsService(AllServices, s) {
+					return fail("%s (%s) 包含不支持的服务 %q", where, step.Name, s)
+				}
+			}
+			if step.Expect != "" && step.Check == "" {
+				return fail("%s (%s) 设置了 expect 但缺少 check", where, step.Name)
+			}
+			if step.Delay < 0 {
+				return fail("%s (%s) 的 delay 不能为负数", where, step.Name)
+			}
+			for _, command := range []string{step.Command, step.Undo, step.Check} {
+				if strings.ContainsAny(command, "\"\\\n") {
+					return fail("%s (%s) 的命令不能包含双引号、反斜杠或换行", where, step.Name)
+				}
+			}
+			if _, err := renderUndo(step.shellStep(r.StepDelay), dummy); err != nil {
+				return fail("%s (%s): %v", where, step.Name, err)
+			}
+		}
+	}
+
+	return nil
+}
+
+// steps 返回指定操作中属于所选服务的步骤
+func (r *Recipe) steps(operation string, services []Service) []shellStep {
+	source := r.Enable
+	if operation == "disable" {
+		source = r.Disable
+	}
+
+	steps := make([]shellStep, 0, len(source))
+	for _, step := range source {
+		steps = append(steps, step.shellStep(r.StepDelay))
+	}
+	return selectSteps(steps, services)
+}
+
+// PriorKeys 返回需要记录的值的键，按字母顺序排列
+func (r *Recipe) PriorKeys() []string {
+	keys := make([]string, 0, len(r.Prior))
+	for key := range r.Prior {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// shellStep 转换为内部的步骤表示
+func (s RecipeStep) shellStep(defaultDelay time.Duration) shellStep {
+	delay := s.Delay
+	if delay == 0 {
+		delay = defaultDelay
+	}
+	return shellStep{
+		name:          s.Name,
+		command:       s.Command,
+		undo:          s.Undo,
+		check:         s.Check,
+		expect:        s.Expect,
+		onlyIfChanged: s.OnlyIfChanged,
+		delay:         delay,
+		services:      s.Services,
+	}
+}
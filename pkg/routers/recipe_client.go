@@ -0,0 +1,608 @@
+package routers
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/bamzest/xiaomi-router-shell-enabler/pkg/logger"
+	"github.com/bamzest/xiaomi-router-shell-enabler/pkg/state"
+	"github.com/bamzest/xiaomi-router-shell-enabler/pkg/utils"
+)
+
+// recipeClient 按内置或用户提供的配方启用/关闭SSH和Telnet的客户端，各型号的客户端在此基础上只需提供配方、命令通道和连接命令
+type recipeClient struct {
+	BaseRouterClient
+	recipe *Recipe
+
+	sshOptions string // 连接此型号的dropbear需要的ssh选项
+}
+
+// recipeClientCapabilities 基于配方的客户端支持的功能
+var recipeClientCapabilities = []Capability{
+	CapEnableShell, CapDisableShell, CapShellStatus, CapExec,
+	CapAuthorizedKeys, CapRootPassword, CapPersist, CapResume, CapRecipe, CapMesh,
+}
+
+// newRecipeClient 创建使用内置配方的客户端
+// 命令通道绑定到客户端的地址，需要在客户端放到最终位置后通过 useChannels 设置
+func newRecipeClient(host, token, model, sshOptions string) recipeClient {
+	return recipeClient{
+		BaseRouterClient: BaseRouterClient{
+			Host:  host,
+			Token: token,
+			Model: model,
+		},
+		recipe:     mustBuiltinRecipe(model),
+		sshOptions: sshOptions,
+	}
+}
+
+// 持久化启动脚本的位置，/data 在重启和OTA升级后都会保留
+const (
+	persistDir        = "/data/auto_ssh"
+	persistScript     = persistDir + "/auto_ssh.sh"
+	persistUCISection = "auto_ssh"
+)
+
+// persistScriptLines 启动脚本的内容，只恢复选择的服务
+// 逐行通过echo写入，因此不能包含单引号、双引号和$；防火墙每次重载都会执行，需要保持幂等
+func persistScriptLines(services []Service) []string {
+	lines := []string{
+		"#!/bin/sh",
+		"# installed by xiaomi-router-shell-enabler, re-enables remote shell on every boot",
+	}
+	if containsService(services, ServiceSSH) {
+		lines = append(lines,
+			"nvram get ssh_en | grep -qx 1 || { nvram set ssh_en=1; nvram commit; }",
+			"grep -q release /etc/init.d/dropbear && { sed -i s/release/debug/g /etc/init.d/dropbear; /etc/init.d/dropbear restart; }",
+			"pidof dropbear > /dev/null || /etc/init.d/dropbear start",
+		)
+	}
+	if containsService(services, ServiceTelnet) {
+		lines = append(lines, "nvram get telnet_en | grep -qx 1 || { nvram set telnet_en=1; nvram commit; }")
+	}
+	return lines
+}
+
+// dropbear 读取root公钥的位置
+const (
+	dropbearConfigDir      = "/etc/dropbear"
+	dropbearAuthorizedKeys = dropbearConfigDir + "/authorized_keys"
+)
+
+// UseRecipe 使用用户提供的配方替代内置配方，配方指定了通道时固定使用该通道
+func (c *recipeClient) UseRecipe(recipe *Recipe) error {
+	if recipe.Channel != "" {
+		if err := c.pinChannel(recipe.Channel); err != nil {
+			return err
+		}
+	}
+	if recipe.Model != "" && recipe.Model != c.Model {
+		logger.Warn("配方 %s 适用于 %s，当前型号为 %s", recipe.Source, recipe.Model, c.Model)
+	}
+
+	logger.Info("使用配方: %s", recipe.Source)
+	c.recipe = recipe
+	return nil
+}
+
+// GetSSHCommand 返回适用于此型号的SSH连接命令
+func (c *recipeClient) GetSSHCommand() string {
+	return c.sshCommand(c.Host)
+}
+
+// sshCommand 返回连接指定主机的SSH命令，Mesh子节点与主路由使用相同的选项
+func (c *recipeClient) sshCommand(host string) string {
+	if c.sshOptions == "" {
+		return fmt.Sprintf("ssh root@%s", host)
+	}
+	return fmt.Sprintf("ssh %s root@%s", c.sshOptions, host)
+}
+
+// SyncRouterTime 同步路由器系统时间
+func (c *recipeClient) SyncRouterTime(ctx context.Context) error {
+	// 获取当前时间的时间戳格式
+	now := time.Now()
+	timeStr := now.Format("2006.01.02-15:04:05")
+
+	// 构建date命令
+	dateCmd := fmt.Sprintf("date -s '%s'", timeStr)
+
+	logger.Info("正在同步路由器系统时间: %s", timeStr)
+
+	// 执行date命令
+	err := c.ExecuteCustomCommand(ctx, dateCmd)
+	if err != nil {
+		return fmt.Errorf("同步系统时间失败: %v", err)
+	}
+
+	logger.Info("路由器系统时间同步成功")
+	return nil
+}
+
+// EnableSSH 启用所选的SSH和Telnet服务，任一步骤失败或被中断时回滚到操作前的状态
+func (c *recipeClient) EnableSSH(ctx context.Context, services []Service) (*ShellResult, error) {
+	result := &ShellResult{Operation: "enable", Services: services}
+
+	// 1. 设置系统时间
+	if err := c.SetSystemTime(ctx); err != nil {
+		return result, err
+	}
+
+	// 2. 执行所选服务的启用步骤
+	steps, err := c.runShellTransaction(ctx, "enable", services, nil)
+	result.Steps = steps
+	if err != nil {
+		return result, err
+	}
+
+	// 3. 验证服务状态
+	result.Status, result.Details = c.verifyEnabled(ctx, services)
+	return result, nil
+}
+
+// DisableSSH 关闭所选的SSH和Telnet服务，任一步骤失败或被中断时回滚到操作前的状态
+func (c *recipeClient) DisableSSH(ctx context.Context, services []Service) (*ShellResult, error) {
+	logger.Info("开始关闭%s服务...", ServiceNames(services))
+	result := &ShellResult{Operation: "disable", Services: services}
+
+	// 执行所选服务的关闭步骤
+	steps, err := c.runShellTransaction(ctx, "disable", services, nil)
+	result.Steps = steps
+	if err != nil {
+		return result, err
+	}
+
+	// 验证服务状态
+	result.Status, result.Details = c.verifyDisabled(ctx, services)
+	return result, nil
+}
+
+// ResumeShell 继续执行上次中断的启用/关闭操作，结果中包含继续的操作和服务
+func (c *recipeClient) ResumeShell(ctx context.Context) (*ShellResult, error) {
+	hostState, err := state.Load(c.Host)
+	if err != nil {
+		return nil, err
+	}
+	if !hostState.InProgress() {
+		return nil, fmt.Errorf("%s 没有未完成的操作", c.Host)
+	}
+
+	services := make([]Service, 0, len(hostState.Services))
+	for _, name := range hostState.Services {
+		services = append(services, Service(name))
+	}
+	operation := hostState.Operation
+	logger.Info("继续上次未完成的操作: %s %s (已完成 %d/%d 步)", operation, ServiceNames(services), hostState.NextStep, len(hostState.Steps))
+
+	result := &ShellResult{Operation: operation, Services: services}
+	result.Steps, err = c.runShellTransaction(ctx, operation, services, hostState)
+	if err != nil {
+		return result, err
+	}
+
+	if operation == "enable" {
+		result.Status, result.Details = c.verifyEnabled(ctx, services)
+	} else {
+		result.Status, result.Details = c.verifyDisabled(ctx, services)
+	}
+	return result, nil
+}
+
+// verifyEnabled 验证服务已启用，返回状态及其详细说明，无法读取状态时返回空
+func (c *recipeClient) verifyEnabled(ctx context.Context, services []Service) (*ShellStatusResult, string) {
+	names := ServiceNames(services)
+	logger.Info("验证%s状态...", names)
+	status, details, err := c.CheckShellStatus(ctx, services)
+	if err != nil {
+		logger.Warn("验证%s状态时出错: %v", names, err)
+		return nil, ""
+	}
+
+	if status.AllReady(services) {
+		logger.Info("%s已成功启用!", names)
+
+		// 如果SSH已成功启用，同步路由器系统时间
+		if status.Ready(ServiceSSH) {
+			logger.Info("SSH已启用，正在同步路由器系统时间...")
+			if syncErr := c.SyncRouterTime(ctx); syncErr != nil {
+				logger.Warn("同步路由器系统时间失败: %v", syncErr)
+			}
+		}
+	} else {
+		logger.Warn("%s可能未成功启用，请查看详细状态", names)
+	}
+	return status, details
+}
+
+// verifyDisabled 验证服务已关闭，返回状态及其详细说明，无法读取状态时返回空
+func (c *recipeClient) verifyDisabled(ctx context.Context, services []Service) (*ShellStatusResult, string) {
+	names := ServiceNames(services)
+	logger.Info("验证%s是否已关闭...", names)
+	status, details, err := c.CheckShellStatus(ctx, services)
+	if err != nil {
+		logger.Warn("验证%s状态时出错: %v", names, err)
+		return nil, ""
+	}
+
+	if status.AnyActive(services) {
+		logger.Warn("%s可能未成功关闭，请查看详细状态", names)
+	} else {
+		logger.Info("%s已成功关闭!", names)
+	}
+	return status, details
+}
+
+// runShellTransaction 记录操作前的状态后以事务方式执行步骤
+// 操作前的状态和每一步的进度都保存到本地状态文件，中断后可以继续执行，回滚失败时也可以据此手动恢复
+// resume 不为空时从其中记录的进度继续，并沿用其中记录的操作前状态；返回本次执行到的步骤的结果
+func (c *recipeClient) runShellTransaction(ctx context.Context, operation string, services []Service, resume *state.HostState) ([]StepResult, error) {
+	steps := c.recipe.steps(operation, services)
+	names := make([]string, len(steps))
+	for i, step := range steps {
+		names[i] = step.name
+	}
+
+	hostState := resume
+	var prior map[string]string
+	if resume != nil {
+		// 步骤不一致说明配方已经变化，进度无法对应
+		if strings.Join(resume.Steps, "\n") != strings.Join(names, "\n") {
+			return nil, fmt.Errorf("配方中的步骤与上次记录的不一致，无法继续执行")
+		}
+		prior = resume.Prior
+	} else {
+		if loaded, err := state.Load(c.Host); err == nil && loaded.InProgress() {
+			logger.Warn("%s 上次的 %s 操作没有完成，可以使用 resume 命令继续，本次将重新开始", c.Host, loaded.Operation)
+		}
+
+		var err error
+		prior, err = c.capturePriorState(ctx, c.ReadCommandOutput)
+		if err != nil {
+			return nil, fmt.Errorf("记录操作前的状态失败: %v", err)
+		}
+		logger.Debug("操作前的状态: %v", prior)
+
+		hostState = &state.HostState{
+			Host:      c.Host,
+			Operation: operation,
+			Prior:     prior,
+			Steps:     names,
+		}
+		for _, s := range services {
+			hostState.Services = append(hostState.Services, string(s))
+		}
+	}
+	hostState.Model = c.Model
+	if err := hostState.Save(); err != nil {
+		logger.Warn("保存状态失败: %v", err)
+	}
+
+	tx, err := newTransaction(c.ExecuteCustomCommand, c.ReadCommandOutput, steps, prior, c.recipe.RollbackCommit)
+	if err != nil {
+		return nil, err
+	}
+	if resume != nil {
+		if err := tx.resumeFrom(resume.NextStep, resume.Executed); err != nil {
+			return nil, err
+		}
+	}
+	tx.onProgress = func(next int, executed []int) {
+		hostState.NextStep = next
+		hostState.Executed = append([]int(nil), executed...)
+		if err := hostState.Save(); err != nil {
+			logger.Warn("保存进度失败: %v", err)
+		}
+	}
+
+	err = tx.run(ctx)
+
+	// 成功完成或已完整回滚时，不再需要保留进度和操作前的状态
+	var rollbackErr *RollbackError
+	if err == nil || !errors.As(err, &rollbackErr) || rollbackErr.Failed == 0 {
+		if clearErr := hostState.Clear(); clearErr != nil {
+			logger.Warn("清除状态失败: %v", clearErr)
+		}
+	} else {
+		logger.Warn("进度已保存，网络恢复后可以使用 resume 命令继续执行")
+	}
+	return tx.Results(), err
+}
+
+// capturePriorState 按配方读取操作前的值，用于回滚
+func (c *recipeClient) capturePriorState(ctx context.Context, probe commandProber) (map[string]string, error) {
+	prior := make(map[string]string, len(c.recipe.Prior))
+	for _, key := range c.recipe.PriorKeys() {
+		value, err := probe(ctx, c.recipe.Prior[key])
+		if err != nil {
+			return nil, fmt.Errorf("读取 %s 失败: %v", key, err)
+		}
+		prior[key] = value
+	}
+	return prior, nil
+}
+
+// runSteps 依次执行步骤，任一步骤失败即返回
+func (c *recipeClient) runSteps(ctx context.Context, steps []commandStep) error {
+	return runCommandSteps(ctx, c.ExecuteCustomCommand, steps)
+}
+
+// InstallAuthorizedKeys 将公钥追加到dropbear的authorized_keys，保留已有条目
+func (c *recipeClient) InstallAuthorizedKeys(ctx context.Context, keys []string) error {
+	for _, key := range keys {
+		if !utils.IsShellSafe(key) {
+			return fmt.Errorf("公钥包含不支持的字符: %s", key)
+		}
+	}
+
+	// 先确保目录和文件存在且权限正确，再逐条追加尚不存在的公钥
+	steps := []commandStep{
+		{"创建Dropbear配置目录", fmt.Sprintf("mkdir -p %s && chmod 700 %s", dropbearConfigDir, dropbearConfigDir)},
+		{"创建authorized_keys", fmt.Sprintf("touch %s && chmod 600 %s", dropbearAuthorizedKeys, dropbearAuthorizedKeys)},
+	}
+	for i, key := range keys {
+		steps = append(steps, commandStep{
+			fmt.Sprintf("写入公钥 %d", i+1),
+			fmt.Sprintf("grep -qxF '%s' %s || echo '%s' >> %s", key, dropbearAuthorizedKeys, key, dropbearAuthorizedKeys),
+		})
+	}
+
+	return c.runSteps(ctx, steps)
+}
+
+// SetRootPassword 设置root密码
+// 密码在本地计算为crypt哈希后写入 /etc/shadow，明文不会出现在路由器的进程命令行中
+func (c *recipeClient) SetRootPassword(ctx context.Context, password string) error {
+	if password == "" {
+		return fmt.Errorf("root密码不能为空")
+	}
+
+	hash, err := utils.MD5Crypt(password, "")
+	if err != nil {
+		return err
+	}
+
+	logger.Info("设置root密码...")
+	command := fmt.Sprintf("sed -i 's|^root:[^:]*:|root:%s:|' /etc/shadow", hash)
+	if err := c.ExecuteCustomCommand(ctx, command); err != nil {
+		return fmt.Errorf("设置root密码失败: %v", err)
+	}
+
+	// 等待命令执行完成
+	if err := sleepContext(ctx, stepDelay); err != nil {
+		return err
+	}
+
+	logger.Info("root密码已设置")
+	return nil
+}
+
+// InstallPersistence 在 /data 中安装启动脚本，并通过UCI防火墙include在每次启动时执行
+// /data 分区在重启和OTA升级后都会保留，脚本会重新解锁dropbear并恢复nvram设置
+func (c *recipeClient) InstallPersistence(ctx context.Context, services []Service) error {
+	logger.Info("安装SSH持久化启动脚本...")
+
+	steps := []commandStep{
+		{"创建持久化目录", fmt.Sprintf("mkdir -p %s", persistDir)},
+	}
+	for i, line := range persistScriptLines(services) {
+		redirect := ">>"
+		if i == 0 {
+			redirect = ">"
+		}
+		steps = append(steps, commandStep{
+			fmt.Sprintf("写入启动脚本 %d", i+1),
+			fmt.Sprintf("echo '%s' %s %s", line, redirect, persistScript),
+		})
+	}
+	steps = append(steps,
+		commandStep{"设置启动脚本权限", fmt.Sprintf("chmod 755 %s", persistScript)},
+		commandStep{"注册启动钩子", fmt.Sprintf("uci set firewall.%s=include && uci set firewall.%s.type=script && uci set firewall.%s.path=%s && uci set firewall.%s.enabled=1",
+			persistUCISection, persistUCISection, persistUCISection, persistScript, persistUCISection)},
+		commandStep{"提交防火墙配置", "uci commit firewall"},
+	)
+
+	if err := c.runSteps(ctx, steps); err != nil {
+		return err
+	}
+
+	logger.Info("SSH持久化启动脚本已安装: %s", persistScript)
+	return nil
+}
+
+// RemovePersistence 删除启动钩子和启动脚本
+func (c *recipeClient) RemovePersistence(ctx context.Context) error {
+	logger.Info("移除SSH持久化启动脚本...")
+
+	steps := []commandStep{
+		{"删除启动钩子", fmt.Sprintf("uci -q delete firewall.%s; uci commit firewall", persistUCISection)},
+		{"删除启动脚本", fmt.Sprintf("rm -rf %s", persistDir)},
+	}
+
+	if err := c.runSteps(ctx, steps); err != nil {
+		return err
+	}
+
+	logger.Info("SSH持久化启动脚本已移除")
+	return nil
+}
+
+// VerifySSHStatus 验证SSH和Telnet状态
+func (c *recipeClient) VerifySSHStatus(ctx context.Context) (bool, error) {
+	// 创建一个状态结构体来跟踪不同的检查结果
+	status := &ShellStatusResult{
+		SSHEnabled:     false,
+		TelnetEnabled:  false,
+		SSHPortOpen:    false,
+		TelnetPortOpen: false,
+	}
+
+	// 1. 首先通过API检查SSH和Telnet的启用状态
+	body, err := c.Get(ctx, "api/xqsystem/fac_info")
+	if err != nil {
+		return false, fmt.Errorf("检查SSH状态失败: %v", err)
+	}
+
+	// 输出调试信息
+	logger.Debug("路由器状态信息: %s", string(body))
+
+	// 检查API返回的状态
+	c.checkAPIStatus(body, status)
+
+	// 2. 然后检查SSH端口(22)和Telnet端口(23)是否开放
+	logger.Info("检查SSH端口(22)是否开放...")
+	status.SSHPortOpen = c.CheckPortOpen(ctx, 22)
+
+	logger.Info("检查Telnet端口(23)是否开放...")
+	status.TelnetPortOpen = c.CheckPortOpen(ctx, 23)
+
+	// 3. 输出详细的状态信息
+	logger.Info("SSH状态检查结果:")
+	logger.Info("  API返回SSH已启用: %v", status.SSHEnabled)
+	logger.Info("  SSH端口(22)开放: %v", status.SSHPortOpen)
+	logger.Info("Telnet状态检查结果:")
+	logger.Info("  API返回Telnet已启用: %v", status.TelnetEnabled)
+	logger.Info("  Telnet端口(23)开放: %v", status.TelnetPortOpen)
+
+	// 4. 综合判断SSH是否成功启用
+	// 如果API返回SSH已启用，且端口22开放，则认为SSH成功启用
+	sshSuccess := status.SSHEnabled && status.SSHPortOpen
+
+	// 如果API返回Telnet已启用，且端口23开放，则认为Telnet成功启用
+	telnetSuccess := status.TelnetEnabled && status.TelnetPortOpen
+
+	if sshSuccess {
+		logger.Info("SSH服务已成功启用并且端口已开放!")
+	} else if status.SSHEnabled {
+		logger.Warn("SSH服务已在配置中启用，但端口22未开放，请检查防火墙设置或服务状态")
+	} else if status.SSHPortOpen {
+		logger.Warn("SSH端口22已开放，但API未返回启用状态，可能是配置未正确保存")
+	} else {
+		logger.Warn("SSH服务未启用，且端口22未开放")
+	}
+
+	if telnetSuccess {
+		logger.Info("Telnet服务已成功启用并且端口已开放!")
+	} else if status.TelnetEnabled {
+		logger.Warn("Telnet服务已在配置中启用，但端口23未开放，请检查防火墙设置或服务状态")
+	} else if status.TelnetPortOpen {
+		logger.Warn("Telnet端口23已开放，但API未返回启用状态，可能是配置未正确保存")
+	} else {
+		logger.Warn("Telnet服务未启用，且端口23未开放")
+	}
+
+	// 返回SSH的成功状态，因为这是主要功能
+	return sshSuccess, nil
+}
+
+// 检查API返回的状态
+func (c *recipeClient) checkAPIStatus(body []byte, status *ShellStatusResult) {
+	// 检查是否为标准JSON格式
+	var result map[string]interface{}
+	err := json.Unmarshal(body, &result)
+	if err != nil {
+		logger.Debug("响应不是标准JSON格式: %v", err)
+		// 尝试其他方法检测SSH状态
+	} else {
+		// 检查是否有code字段，这是标准小米API响应格式
+		if code, ok := result["code"].(float64); ok {
+			if code != 0 {
+				logger.Debug("API返回错误码: %v, 消息: %v", code, result["msg"])
+				return
+			}
+		}
+
+		// 直接检查ssh字段，这是AX5400Pro等较新固件的响应格式
+		if ssh, ok := result["ssh"].(bool); ok {
+			logger.Info("检测到SSH状态: %v", ssh)
+			status.SSHEnabled = ssh
+		}
+
+		// 检查telnet字段
+		if telnet, ok := result["telnet"].(bool); ok {
+			logger.Info("检测到Telnet状态: %v", telnet)
+			status.TelnetEnabled = telnet
+		}
+
+		// 检查是否存在ssh_en字段，这是一些其他小米路由器的响应格式
+		if data, ok := result["data"].(map[string]interface{}); ok {
+			if sshEn, exists := data["ssh_en"]; exists {
+				if sshEnStr, ok := sshEn.(string); ok && sshEnStr == "1" {
+					logger.Info("检测到SSH已启用 (ssh_en=1)")
+					status.SSHEnabled = true
+				} else if sshEnBool, ok := sshEn.(bool); ok && sshEnBool {
+					logger.Info("检测到SSH已启用 (ssh_en=true)")
+					status.SSHEnabled = true
+				}
+			}
+
+			// 检查telnet_en字段
+			if telnetEn, exists := data["telnet_en"]; exists {
+				if telnetEnStr, ok := telnetEn.(string); ok && telnetEnStr == "1" {
+					logger.Info("检测到Telnet已启用 (telnet_en=1)")
+					status.TelnetEnabled = true
+				} else if telnetEnBool, ok := telnetEn.(bool); ok && telnetEnBool {
+					logger.Info("检测到Telnet已启用 (telnet_en=true)")
+					status.TelnetEnabled = true
+				}
+			}
+		}
+	}
+
+	// 方法2: 检查响应中是否包含SSH相关信息
+	bodyStr := string(body)
+	if strings.Contains(bodyStr, `"ssh":true`) ||
+		strings.Contains(bodyStr, `"ssh": true`) ||
+		strings.Contains(bodyStr, `"ssh_en":"1"`) ||
+		strings.Contains(bodyStr, `"ssh_en": "1"`) ||
+		strings.Contains(bodyStr, `"ssh_en":1`) ||
+		strings.Contains(bodyStr, `"ssh_en": 1`) {
+		logger.Info("检测到SSH已启用 (响应中包含SSH启用标识)")
+		status.SSHEnabled = true
+	}
+
+	// 检查响应中是否包含Telnet相关信息
+	if strings.Contains(bodyStr, `"telnet":true`) ||
+		strings.Contains(bodyStr, `"telnet": true`) ||
+		strings.Contains(bodyStr, `"telnet_en":"1"`) ||
+		strings.Contains(bodyStr, `"telnet_en": "1"`) ||
+		strings.Contains(bodyStr, `"telnet_en":1`) ||
+		strings.Contains(bodyStr, `"telnet_en": 1`) {
+		logger.Info("检测到Telnet已启用 (响应中包含Telnet启用标识)")
+		status.TelnetEnabled = true
+	}
+}
+
+// CheckShellStatus 检查所选服务的状态 (覆写基类方法)
+func (c *recipeClient) CheckShellStatus(ctx context.Context, services []Service) (*ShellStatusResult, string, error) {
+	logger.Info("检查 %s 路由器的%s状态...", c.Model, ServiceNames(services))
+
+	// 创建状态结构体
+	status := &ShellStatusResult{}
+
+	// 1. 通过API检查SSH和Telnet的启用状态
+	body, err := c.Get(ctx, "api/xqsystem/fac_info")
+	if err != nil {
+		return nil, "", fmt.Errorf("检查状态失败: %v", err)
+	}
+
+	// 检查API返回的状态
+	c.checkAPIStatus(body, status)
+
+	// 2. 检查所选服务的端口是否开放
+	for _, service := range services {
+		switch service {
+		case ServiceSSH:
+			status.SSHPortOpen = c.CheckPortOpen(ctx, service.Port())
+		case ServiceTelnet:
+			status.TelnetPortOpen = c.CheckPortOpen(ctx, service.Port())
+		}
+	}
+
+	// 3. 生成详细状态报告，使用特定于型号的连接命令
+	return status, FormatShellStatus(status, services, c.GetSSHCommand(), c.GetTelnetCommand()), nil
+}
@@ -0,0 +1,78 @@
+package routers
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestBuiltinRecipes(t *testing.T) {
+	for _, desc := range Models() {
+		r, err := BuiltinRecipe(desc.ID)
+		if err != nil {
+			t.Errorf("%s: %v", desc.ID, err)
+			continue
+		}
+		// 命令通道由型号决定，内置配方固定通道会绕过型号声明的尝试顺序
+		if r.Channel != "" {
+			t.Errorf("%s: 内置配方不应指定 channel", desc.ID)
+		}
+		if r.RollbackCommit == "" {
+			t.Errorf("%s: 缺少 rollback_commit", desc.ID)
+		}
+		// 提交步骤如果有撤销命令，回滚时会在恢复值之前把修改后的值写入flash
+		for _, step := range append(r.Enable, r.Disable...) {
+			if strings.TrimSpace(step.Command) == r.RollbackCommit && step.Undo != "" {
+				t.Errorf("%s: 步骤 %s 不应有撤销命令", desc.ID, step.Name)
+			}
+		}
+	}
+}
+
+const validRecipe = `
+model: test
+channel: smartcontroller
+rollback_commit: nvram commit
+prior:
+  ssh_en: nvram get ssh_en
+enable:
+  - name: 启用SSH配置
+    command: nvram set ssh_en=1
+    undo: nvram set ssh_en={{.ssh_en}}
+disable:
+  - name: 禁用SSH配置
+    command: nvram set ssh_en=0
+    undo: nvram set ssh_en={{.ssh_en}}
+`
+
+func TestRecipeValidate(t *testing.T) {
+	if _, err := ParseRecipe([]byte(validRecipe), "test"); err != nil {
+		t.Fatalf("有效的配方解析失败: %v", err)
+	}
+	// model 和 channel 都可以省略
+	generic := strings.NewReplacer("model: test\n", "", "channel: smartcontroller\n", "").Replace(validRecipe)
+	if _, err := ParseRecipe([]byte(generic), "test"); err != nil {
+		t.Errorf("省略 model 和 channel 的配方解析失败: %v", err)
+	}
+
+	for _, tc := range []struct {
+		name    string
+		replace [2]string
+		errPart string
+	}{
+		{"未知通道", [2]string{"channel: smartcontroller", "channel: unknown"}, "不支持的命令通道"},
+		{"未记录的值", [2]string{"{{.ssh_en}}\ndisable", "{{.telnet_en}}\ndisable"}, "enable[0]"},
+		{"命令包含双引号", [2]string{"command: nvram set ssh_en=1", `command: nvram set ssh_en="1"`}, "双引号"},
+		{"提交命令包含换行", [2]string{"rollback_commit: nvram commit", `rollback_commit: "nvram commit\nreboot"`}, "rollback_commit"},
+		{"expect缺少check", [2]string{"undo: nvram set ssh_en={{.ssh_en}}\ndisable", "undo: nvram set ssh_en={{.ssh_en}}\n    expect: \"1\"\ndisable"}, "缺少 check"},
+		{"没有步骤", [2]string{"disable:", "other:"}, "disable 中没有步骤"},
+	} {
+		data := strings.Replace(validRecipe, tc.replace[0], tc.replace[1], 1)
+		if data == validRecipe {
+			t.Fatalf("%s: 替换没有生效", tc.name)
+		}
+		_, err := ParseRecipe([]byte(data), "test")
+		if err == nil || !strings.Contains(err.Error(), tc.errPart) {
+			t.Errorf("%s: 错误为 %v，期望包含 %q", tc.name, err, tc.errPart)
+		}
+	}
+}
@@ -0,0 +1,124 @@
+package routers
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/bamzest/xiaomi-router-shell-enabler/pkg/auth"
+)
+
+// Capability 型号支持的功能
+type Capability string
+
+const (
+	CapEnableShell    Capability = "enable_shell"
+	CapDisableShell   Capability = "disable_shell"
+	CapShellStatus    Capability = "shell_status"
+	CapExec           Capability = "exec"
+	CapAuthorizedKeys Capability = "authorized_keys"
+	CapRootPassword   Capability = "root_password"
+	CapPersist        Capability = "persist"
+	CapResume         Capability = "resume"
+	CapRecipe         Capability = "recipe"
+	CapMesh           Capability = "mesh"
+)
+
+// ModelDescriptor 描述一个支持的型号，由各型号的客户端在 init 中注册
+type ModelDescriptor struct {
+	ID           string          // 型号名，即 -model 的取值
+	Aliases      []string        // 其他可接受的写法
+	DisplayName  string          // 显示名称
+	Hardware     []string        // init_info 返回的硬件代号，用于自动识别
+	HashMode     auth.HashMode   // 登录时密码的哈希算法
+	Firmware     []FirmwareRange // 已知可用和已知被修补的固件版本，未列出的版本视为未测试
+	Channels     []string        // 支持的命令通道，按尝试顺序排列
+	Capabilities []Capability    // 支持的功能
+
+	// New 使用登录得到的stok创建客户端
+	New func(host, token string) RouterClient
+}
+
+// Supports 型号是否支持指定功能
+func (d *ModelDescriptor) Supports(capability Capability) bool {
+	for _, c := range d.Capabilities {
+		if c == capability {
+			return true
+		}
+	}
+	return false
+}
+
+// 已注册的型号，键为规范化后的型号名和别名
+var (
+	modelRegistry    = map[string]*ModelDescriptor{}
+	hardwareRegistry = map[string]*ModelDescriptor{}
+	modelList        []*ModelDescriptor
+)
+
+// RegisterModel 注册型号，ID或别名重复属于程序错误
+func RegisterModel(d ModelDescriptor) {
+	if d.ID == "" || d.New == nil {
+		panic("routers: 注册型号时缺少 ID 或 New")
+	}
+
+	desc := &d
+	for _, name := range append([]string{d.ID}, d.Aliases...) {
+		key := normalizeModelName(name)
+		if existing, ok := modelRegistry[key]; ok && existing != desc {
+			panic(fmt.Sprintf("routers: 型号名 %q 与 %s 重复", name, existing.ID))
+		}
+		modelRegistry[key] = desc
+	}
+	for _, code := range d.Hardware {
+		key := strings.ToUpper(code)
+		if existing, ok := hardwareRegistry[key]; ok {
+			panic(fmt.Sprintf("routers: 硬件代号 %q 与 %s 重复", code, existing.ID))
+		}
+		hardwareRegistry[key] = desc
+	}
+
+	modelList = append(modelList, desc)
+	sort.Slice(modelList, func(i, j int) bool {
+		return modelList[i].ID < modelList[j].ID
+	})
+}
+
+// LookupModel 按型号名、别名或硬件代号查找型号，忽略大小写、空格、下划线和连字符
+func LookupModel(name string) (*ModelDescriptor, bool) {
+	if desc, ok := modelRegistry[normalizeModelName(name)]; ok {
+		return desc, true
+	}
+	return LookupHardware(name)
+}
+
+// LookupHardware 按硬件代号查找型号
+func LookupHardware(code string) (*ModelDescriptor, bool) {
+	desc, ok := hardwareRegistry[strings.ToUpper(strings.TrimSpace(code))]
+	return desc, ok
+}
+
+// Models 返回所有已注册的型号，按ID排序
+func Models() []*ModelDescriptor {
+	return append([]*ModelDescriptor(nil), modelList...)
+}
+
+// ModelIDs 返回所有已注册型号的ID
+func ModelIDs() []string {
+	ids := make([]string, 0, len(modelList))
+	for _, d := range modelList {
+		ids = append(ids, d.ID)
+	}
+	return ids
+}
+
+// normalizeModelName 规范化型号名，"Redmi AX5400 Pro" 和 "redmi_ax5400pro" 视为相同
+func normalizeModelName(name string) string {
+	return strings.Map(func(r rune) rune {
+		switch r {
+		case ' ', '_', '-':
+			return -1
+		}
+		return r
+	}, strings.ToLower(strings.TrimSpace(name)))
+}
@@ -0,0 +1,67 @@
+package routers
+
+import "context"
+
+// RouterClient 路由器客户端接口
+type RouterClient interface {
+	// SetSystemTime 设置系统时间
+	SetSystemTime(ctx context.Context) error
+
+	// EnableSSH 启用所选的SSH和Telnet服务，失败或ctx被取消时回滚
+	EnableSSH(ctx context.Context, services []Service) (*ShellResult, error)
+
+	// DisableSSH 关闭所选的SSH和Telnet服务，失败或ctx被取消时回滚
+	DisableSSH(ctx context.Context, services []Service) (*ShellResult, error)
+
+	// UseRecipe 使用用户提供的配方替代内置配方
+	UseRecipe(recipe *Recipe) error
+
+	// InstallAuthorizedKeys 将公钥写入dropbear的authorized_keys
+	InstallAuthorizedKeys(ctx context.Context, keys []string) error
+
+	// SetRootPassword 设置root密码
+	SetRootPassword(ctx context.Context, password string) error
+
+	// InstallPersistence 安装启动脚本，使SSH在重启和固件升级后保持启用
+	InstallPersistence(ctx context.Context, services []Service) error
+
+	// RemovePersistence 移除启动脚本
+	RemovePersistence(ctx context.Context) error
+
+	// Reboot 重启路由器
+	Reboot(ctx context.Context) error
+
+	// ResumeShell 继续执行上次中断的启用/关闭操作，结果中包含继续的操作和服务
+	ResumeShell(ctx context.Context) (*ShellResult, error)
+
+	// VerifySSHStatus 验证SSH状态
+	VerifySSHStatus(ctx context.Context) (bool, error)
+
+	// ExecuteCustomCommand 执行自定义命令
+	ExecuteCustomCommand(ctx context.Context, command string) error
+
+	// CheckShellStatus 检查所选服务的状态
+	// 返回值：按服务区分的状态, 详细状态信息(string), 错误(error)
+	CheckShellStatus(ctx context.Context, services []Service) (*ShellStatusResult, string, error)
+
+	// GetSerialNumber 读取路由器的序列号，用于计算SSH密码
+	GetSerialNumber(ctx context.Context) (string, error)
+
+	// ListMeshNodes 列出Mesh网络中的节点，第一个为主路由
+	ListMeshNodes(ctx context.Context) ([]MeshNode, error)
+
+	// EnableMeshNode 通过主路由在Mesh子节点上启用所选服务，返回子节点的状态
+	EnableMeshNode(ctx context.Context, node MeshNode, services []Service) (*ShellStatusResult, string, error)
+
+	// CheckMeshNodeStatus 通过主路由检查Mesh子节点的服务状态
+	CheckMeshNodeStatus(ctx context.Context, node MeshNode, services []Service) (*ShellStatusResult, string, error)
+
+	// Cleanup 删除本次运行在路由器上创建的临时任务并注销登录
+	Cleanup(ctx context.Context) error
+
+	// GetSSHCommand 获取适用于此型号的SSH连接命令
+	GetSSHCommand() string
+
+	// GetTelnetCommand 获取适用于此型号的Telnet连接命令
+	GetTelnetCommand() string
+}
@@ -0,0 +1,148 @@
+package routers
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Service 可以单独启用和关闭的远程登录服务
+type Service string
+
+const (
+	ServiceSSH    Service = "ssh"
+	ServiceTelnet Service = "telnet"
+)
+
+// AllServices 所有支持的服务，顺序即输出顺序
+var AllServices = []Service{ServiceSSH, ServiceTelnet}
+
+// DisplayName 服务的显示名称
+func (s Service) DisplayName() string {
+	switch s {
+	case ServiceSSH:
+		return "SSH"
+	case ServiceTelnet:
+		return "Telnet"
+	default:
+		return string(s)
+	}
+}
+
+// Port 服务的端口
+func (s Service) Port() int {
+	switch s {
+	case ServiceSSH:
+		return 22
+	case ServiceTelnet:
+		return 23
+	default:
+		return 0
+	}
+}
+
+// ParseServices 解析逗号分隔的服务列表，如 "ssh,telnet"
+func ParseServices(value string) ([]Service, error) {
+	var services []Service
+	for _, part := range strings.Split(value, ",") {
+		name := strings.ToLower(strings.TrimSpace(part))
+		if name == "" {
+			continue
+		}
+
+		service := Service(name)
+		if !containsService(AllServices, service) {
+			return nil, fmt.Errorf("不支持的服务: %s (可选: ssh, telnet)", part)
+		}
+		if !containsService(services, service) {
+			services = append(services, service)
+		}
+	}
+
+	if len(services) == 0 {
+		return nil, fmt.Errorf("至少需要指定一个服务")
+	}
+	return services, nil
+}
+
+// ServiceNames 生成服务列表的显示名称，如 "SSH和Telnet"
+func ServiceNames(services []Service) string {
+	names := make([]string, 0, len(services))
+	for _, s := range services {
+		names = append(names, s.DisplayName())
+	}
+	return strings.Join(names, "和")
+}
+
+// containsService 判断服务列表中是否包含指定服务
+func containsService(services []Service, service Service) bool {
+	for _, s := range services {
+		if s == service {
+			return true
+		}
+	}
+	return false
+}
+
+// Enabled 配置中是否启用了指定服务
+func (r *ShellStatusResult) Enabled(service Service) bool {
+	switch service {
+	case ServiceSSH:
+		return r.SSHEnabled
+	case ServiceTelnet:
+		return r.TelnetEnabled
+	default:
+		return false
+	}
+}
+
+// PortOpen 指定服务的端口是否开放
+func (r *ShellStatusResult) PortOpen(service Service) bool {
+	switch service {
+	case ServiceSSH:
+		return r.SSHPortOpen
+	case ServiceTelnet:
+		return r.TelnetPortOpen
+	default:
+		return false
+	}
+}
+
+// Ready 指定服务是否已启用并且可以访问
+func (r *ShellStatusResult) Ready(service Service) bool {
+	return r.Enabled(service) && r.PortOpen(service)
+}
+
+// AllReady 列表中的服务是否全部已启用并且可以访问
+func (r *ShellStatusResult) AllReady(services []Service) bool {
+	for _, s := range services {
+		if !r.Ready(s) {
+			return false
+		}
+	}
+	return true
+}
+
+// AnyActive 列表中是否有服务仍在配置中启用或端口仍开放
+func (r *ShellStatusResult) AnyActive(services []Service) bool {
+	for _, s := range services {
+		if r.Enabled(s) || r.PortOpen(s) {
+			return true
+		}
+	}
+	return false
+}
+
+// Summary 指定服务的总体状态描述
+func (r *ShellStatusResult) Summary(service Service) string {
+	name := service.DisplayName()
+	switch {
+	case r.Ready(service):
+		return fmt.Sprintf("%s已成功启用并且可以访问", name)
+	case r.Enabled(service):
+		return fmt.Sprintf("%s在配置中已启用，但端口未开放", name)
+	case r.PortOpen(service):
+		return fmt.Sprintf("%s端口已开放，但配置中未启用", name)
+	default:
+		return fmt.Sprintf("%s未启用", name)
+	}
+}
@@ -0,0 +1,259 @@
+package routers
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/bamzest/xiaomi-router-shell-enabler/pkg/logger"
+)
+
+// 通过智能场景的定时任务执行命令，场景名会被未经转义地拼入shell命令
+const ChannelSmartController = "smartcontroller"
+
+func init() {
+	RegisterChannel(ChannelSmartController, func(client *BaseRouterClient) CommandChannel {
+		return &smartControllerChannel{client: client}
+	})
+}
+
+// smartControllerChannel 智能控制器命令通道
+type smartControllerChannel struct {
+	client *BaseRouterClient
+
+	// 本次运行创建的智能场景，退出前删除
+	scenes []string
+}
+
+// Name 通道名称
+func (ch *smartControllerChannel) Name() string {
+	return ChannelSmartController
+}
+
+// 任务时间缓存文件路径
+const taskTimeCacheFile = ".task_time_cache"
+
+// 获取并递增任务时间
+func getNextTaskTime() string {
+	hour, minute := -1, -1 // 初始化为-1表示未设置
+
+	// 获取程序执行目录
+	exePath, err := os.Executable()
+	if err != nil {
+		logger.Debug("无法获取程序执行路径: %v，将使用当前目录", err)
+		exePath, _ = os.Getwd()
+	}
+
+	// 使用程序所在目录
+	programDir := filepath.Dir(exePath)
+	cachePath := filepath.Join(programDir, taskTimeCacheFile)
+	logger.Debug("任务时间缓存文件路径: %s", cachePath)
+
+	// 尝试读取缓存文件
+	content, err := ioutil.ReadFile(cachePath)
+	if err == nil {
+		// 文件存在，解析内容
+		parts := strings.Split(string(content), ":")
+		if len(parts) == 2 {
+			hourVal, hourErr := strconv.Atoi(parts[0])
+			minVal, minErr := strconv.Atoi(parts[1])
+
+			if hourErr == nil && minErr == nil {
+				hour = hourVal
+				minute = minVal
+
+				// 递增分钟
+				minute++
+				if minute >= 60 {
+					minute = 0
+					hour++
+					if hour >= 24 {
+						hour = 0
+					}
+				}
+			} else {
+				logger.Debug("解析缓存文件失败: %v, %v，将使用当前时间+1分钟", hourErr, minErr)
+				hour = -1 // 重置为未设置状态
+			}
+		} else {
+			logger.Debug("缓存文件格式不正确，将使用当前时间+1分钟")
+		}
+	} else {
+		logger.Debug("读取缓存文件失败: %v，将使用当前时间+1分钟", err)
+	}
+
+	// 如果未设置时间（首次运行或缓存文件读取失败），使用当前时间+1分钟
+	if hour == -1 || minute == -1 {
+		now := time.Now().Add(1 * time.Minute)
+		hour = now.Hour()
+		minute = now.Minute()
+		logger.Debug("使用当前时间+1分钟: %d:%d", hour, minute)
+	}
+
+	// 将新的时间写入缓存文件
+	newTimeStr := fmt.Sprintf("%d:%d", hour, minute)
+	err = ioutil.WriteFile(cachePath, []byte(newTimeStr), 0644)
+	if err != nil {
+		logger.Debug("写入缓存文件失败: %v", err)
+
+		// 如果写入失败，尝试在当前工作目录创建
+		currentDir, _ := os.Getwd()
+		fallbackPath := filepath.Join(currentDir, taskTimeCacheFile)
+		logger.Debug("尝试使用备用路径: %s", fallbackPath)
+
+		err = ioutil.WriteFile(fallbackPath, []byte(newTimeStr), 0644)
+		if err != nil {
+			logger.Debug("写入备用缓存文件也失败: %v", err)
+		}
+	}
+
+	logger.Debug("使用任务时间: %s", newTimeStr)
+	return newTimeStr
+}
+
+// setTask 设置智能控制器任务，场景名即要执行的命令
+func (ch *smartControllerChannel) setTask(ctx context.Context, command, taskTime string) error {
+	payload := fmt.Sprintf(`{"command":"scene_setting","name":"%s","action_list":[{"thirdParty":"xmrouter","delay":17,"type":"wan_block","payload":{"command":"wan_block","mac":"00:00:00:00:00:00"}}],"launch":{"timer":{"time":"%s","repeat":"0","enabled":true}}}`, command, taskTime)
+	encodedPayload := url.QueryEscape(payload)
+
+	data := fmt.Sprintf("payload=%s", encodedPayload)
+
+	logger.Debug("设置智能控制器任务")
+	logger.Debug("原始Payload: %s", payload)
+	logger.Debug("URL编码后Payload: %s", encodedPayload)
+
+	respBody, err := ch.client.Post(ctx, "api/xqsmarthome/request_smartcontroller", data)
+	if err != nil {
+		logger.Debug("设置智能控制器任务请求失败: %v", err)
+		return err
+	}
+
+	// 解析响应
+	var resp APIResponse
+	if err := json.Unmarshal(respBody, &resp); err != nil {
+		logger.Debug("解析响应失败: %v, 原始响应: %s", err, string(respBody))
+		return fmt.Errorf("解析响应失败: %v", err)
+	}
+
+	logger.Debug("设置智能控制器任务响应: code=%d, msg=%s", resp.Code, resp.Msg)
+
+	// 检查响应状态
+	if resp.Code != 0 {
+		return fmt.Errorf("API错误: %s (代码: %d)", resp.Msg, resp.Code)
+	}
+
+	ch.scenes = append(ch.scenes, command)
+	return nil
+}
+
+// deleteTask 删除智能控制器任务
+func (ch *smartControllerChannel) deleteTask(ctx context.Context, name string) error {
+	payload := fmt.Sprintf(`{"command":"scene_delete","name":"%s"}`, name)
+	data := fmt.Sprintf("payload=%s", url.QueryEscape(payload))
+
+	logger.Debug("删除智能控制器任务: %s", name)
+
+	respBody, err := ch.client.Post(ctx, "api/xqsmarthome/request_smartcontroller", data)
+	if err != nil {
+		return err
+	}
+
+	var resp APIResponse
+	if err := json.Unmarshal(respBody, &resp); err != nil {
+		return fmt.Errorf("解析响应失败: %v", err)
+	}
+	if resp.Code != 0 {
+		return fmt.Errorf("API错误: %s (代码: %d)", resp.Msg, resp.Code)
+	}
+	return nil
+}
+
+// Cleanup 删除本次运行创建的智能场景，尽力而为，单个失败不影响其余清理
+func (ch *smartControllerChannel) Cleanup(ctx context.Context) error {
+	if len(ch.scenes) > 0 {
+		logger.Debug("清理 %d 个智能场景...", len(ch.scenes))
+	}
+
+	failed := 0
+	for _, name := range ch.scenes {
+		if err := ch.deleteTask(ctx, name); err != nil {
+			logger.Debug("删除智能场景失败: %v", err)
+			failed++
+		}
+	}
+	ch.scenes = nil
+
+	if failed > 0 {
+		return fmt.Errorf("有 %d 个智能场景删除失败", failed)
+	}
+	return nil
+}
+
+// startTask 启动智能控制器任务
+func (ch *smartControllerChannel) startTask(ctx context.Context, taskTime string, week int) error {
+	payload := fmt.Sprintf(`{"command":"scene_start_by_crontab","time":"%s","week":%d}`, taskTime, week)
+	encodedPayload := url.QueryEscape(payload)
+
+	data := fmt.Sprintf("payload=%s", encodedPayload)
+
+	logger.Debug("启动智能控制器任务")
+	logger.Debug("原始Payload: %s", payload)
+	logger.Debug("URL编码后Payload: %s", encodedPayload)
+
+	respBody, err := ch.client.Post(ctx, "api/xqsmarthome/request_smartcontroller", data)
+	if err != nil {
+		logger.Debug("启动智能控制器任务请求失败: %v", err)
+		return err
+	}
+
+	// 解析响应
+	var resp APIResponse
+	if err := json.Unmarshal(respBody, &resp); err != nil {
+		logger.Debug("解析响应失败: %v, 原始响应: %s", err, string(respBody))
+		return fmt.Errorf("解析响应失败: %v", err)
+	}
+
+	logger.Debug("启动智能控制器任务响应: code=%d, msg=%s", resp.Code, resp.Msg)
+
+	// 检查响应状态
+	if resp.Code != 0 {
+		return fmt.Errorf("API错误: %s (代码: %d)", resp.Msg, resp.Code)
+	}
+
+	return nil
+}
+
+// Execute 将命令作为场景名创建定时场景并立即触发，场景名在执行时被shell展开
+func (ch *smartControllerChannel) Execute(ctx context.Context, command string) error {
+	// 格式化命令，确保它能在路由器上正确执行
+	formattedCommand := fmt.Sprintf("'$(%s)'", command)
+
+	// 获取任务时间
+	taskTime := getNextTaskTime()
+
+	// 设置任务
+	err := ch.setTask(ctx, formattedCommand, taskTime)
+	if err != nil {
+		return fmt.Errorf("设置任务失败: %v", err)
+	}
+
+	// 执行任务
+	err = ch.startTask(ctx, taskTime, 0)
+	if err != nil {
+		return fmt.Errorf("执行任务失败: %v", err)
+	}
+
+	// 等待一秒，确保命令执行
+	if err := sleepContext(ctx, 1*time.Second); err != nil {
+		return err
+	}
+
+	return nil
+}
@@ -0,0 +1,362 @@
+package routers
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"text/template"
+	"time"
+
+	"github.com/bamzest/xiaomi-router-shell-enabler/pkg/logger"
+)
+
+// 每个步骤之间的等待时间，确保命令执行完成
+const stepDelay = 2 * time.Second
+
+// 回滚的最长时间
+const rollbackTimeout = 2 * time.Minute
+
+// ErrInterrupted 操作被用户中断
+var ErrInterrupted = errors.New("操作被中断")
+
+// commandExecutor 通过命令通道在路由器上执行一条命令
+type commandExecutor func(ctx context.Context, command string) error
+
+// commandStep 通过命令通道执行的一个步骤
+type commandStep struct {
+	name    string
+	command string
+}
+
+// shellStep 启用/关闭流程中的一个步骤，services 为空表示与服务无关的公共步骤
+// undo 为撤销命令模板，可以引用操作前记录的配置值，如 {{.ssh_en}}
+// check 为前置检查命令，输出等于 expect 时说明已处于目标状态，步骤会被跳过
+// onlyIfChanged 的步骤（如提交、重启）仅在前面有步骤实际执行时才执行，否则以 check 的结果为准
+// delay 为执行后的等待时间，为0时使用默认值
+type shellStep struct {
+	name          string
+	command       string
+	undo          string
+	check         string
+	expect        string
+	onlyIfChanged bool
+	delay         time.Duration
+	services      []Service
+}
+
+// selectSteps 挑选出属于所选服务的步骤
+// 只服务于其他服务的步骤会被跳过，公共步骤始终保留
+func selectSteps(steps []shellStep, services []Service) []shellStep {
+	var selected []shellStep
+	for _, step := range steps {
+		include := len(step.services) == 0
+		for _, s := range step.services {
+			if containsService(services, s) {
+				include = true
+				break
+			}
+		}
+		if include {
+			selected = append(selected, step)
+		}
+	}
+	return selected
+}
+
+// runCommandSteps 依次执行不需要回滚的步骤，任一步骤失败即返回
+func runCommandSteps(ctx context.Context, exec commandExecutor, steps []commandStep) error {
+	for i, step := range steps {
+		logger.Info("[%d/%d] %s...", i+1, len(steps), step.name)
+
+		if err := exec(ctx, step.command); err != nil {
+			return fmt.Errorf("%s失败: %v", step.name, err)
+		}
+
+		logger.Info("%s完成", step.name)
+		if err := sleepContext(ctx, stepDelay); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// StepStatus 步骤的执行结果
+type StepStatus string
+
+const (
+	StepExecuted       StepStatus = "executed"        // 已执行
+	StepSkipped        StepStatus = "skipped"         // 已是目标状态，跳过
+	StepFailed         StepStatus = "failed"          // 执行失败
+	StepRolledBack     StepStatus = "rolled_back"     // 已执行，之后被回滚
+	StepRollbackFailed StepStatus = "rollback_failed" // 已执行，回滚失败
+)
+
+// StepResult 一个步骤的执行结果
+type StepResult struct {
+	Name   string     `json:"name"`
+	Status StepStatus `json:"status"`
+	Error  string     `json:"error,omitempty"`
+}
+
+// transaction 以事务方式执行的一组步骤
+// 每个步骤执行前先进行前置检查，已处于目标状态的步骤会被跳过，避免重复写入flash
+// 任一步骤失败或ctx被取消时，按相反顺序执行已执行步骤（包括失败的步骤）的撤销命令，恢复到prior记录的状态
+// 撤销命令执行完后再执行一次 commit，把恢复的值写入flash；提交类的步骤本身不应有撤销命令，
+// 否则会在恢复值之前把修改后的值写入flash
+type transaction struct {
+	exec   commandExecutor
+	probe  commandProber
+	steps  []shellStep
+	undos  []string
+	commit string
+
+	next     int          // 下一个要执行的步骤
+	executed []int        // 实际执行过的步骤，回滚时只撤销这些步骤
+	results  []StepResult // 本次运行中每个步骤的结果，未执行到的步骤为空
+
+	// 每次执行或撤销一个步骤后调用，用于保存进度以便中断后继续
+	onProgress func(next int, executed []int)
+}
+
+// newTransaction 创建事务，先渲染所有撤销命令，避免执行到一半才发现无法回滚
+// commit 为回滚的最后执行一次的命令，为空表示不需要
+func newTransaction(exec commandExecutor, probe commandProber, steps []shellStep, prior map[string]string, commit string) (*transaction, error) {
+	undos := make([]string, len(steps))
+	for i, step := range steps {
+		undo, err := renderUndo(step, prior)
+		if err != nil {
+			return nil, err
+		}
+		undos[i] = undo
+	}
+	return &transaction{exec: exec, probe: probe, steps: steps, undos: undos, commit: commit, results: make([]StepResult, len(steps))}, nil
+}
+
+// record 记录步骤的结果，回滚时保留步骤失败的原因
+func (t *transaction) record(i int, status StepStatus, err error) {
+	t.results[i] = StepResult{Name: t.steps[i].name, Status: status, Error: t.results[i].Error}
+	if err != nil {
+		t.results[i].Error = err.Error()
+	}
+}
+
+// Results 本次运行中执行到的步骤的结果，按步骤顺序排列
+func (t *transaction) Results() []StepResult {
+	var results []StepResult
+	for _, r := range t.results {
+		if r.Status != "" {
+			results = append(results, r)
+		}
+	}
+	return results
+}
+
+// resumeFrom 从上次中断的位置继续，executed 为上次已实际执行的步骤
+func (t *transaction) resumeFrom(next int, executed []int) error {
+	if next < 0 || next > len(t.steps) {
+		return fmt.Errorf("无效的进度: %d/%d", next, len(t.steps))
+	}
+	for _, i := range executed {
+		if i < 0 || i >= len(t.steps) {
+			return fmt.Errorf("无效的进度: 已执行步骤 %d 不存在", i+1)
+		}
+	}
+	t.next = next
+	t.executed = append([]int(nil), executed...)
+	return nil
+}
+
+// saveProgress 通知进度变化
+func (t *transaction) saveProgress() {
+	if t.onProgress != nil {
+		t.onProgress(t.next, t.executed)
+	}
+}
+
+// run 从当前进度开始执行剩余步骤
+func (t *transaction) run(ctx context.Context) error {
+	changed := len(t.executed) > 0
+	if t.next > 0 {
+		logger.Info("从第 %d/%d 步继续执行", t.next+1, len(t.steps))
+	}
+
+	for ; t.next < len(t.steps); t.next++ {
+		i, step := t.next, t.steps[t.next]
+		if ctx.Err() != nil {
+			return t.rollback(ErrInterrupted)
+		}
+
+		if !stepNeeded(ctx, t.probe, step, changed) {
+			logger.Info("[%d/%d] %s: 已是目标状态，跳过", i+1, len(t.steps), step.name)
+			t.record(i, StepSkipped, nil)
+			continue
+		}
+
+		logger.Info("[%d/%d] %s...", i+1, len(t.steps), step.name)
+
+		// 回滚失败后继续执行时，同一步骤可能已在记录中
+		if !containsInt(t.executed, i) {
+			t.executed = append(t.executed, i)
+		}
+		if err := t.exec(ctx, step.command); err != nil {
+			t.record(i, StepFailed, err)
+			if ctx.Err() != nil {
+				return t.rollback(ErrInterrupted)
+			}
+			return t.rollback(fmt.Errorf("%s失败: %v", step.name, err))
+		}
+		changed = true
+		t.record(i, StepExecuted, nil)
+
+		logger.Info("%s完成", step.name)
+		t.next = i + 1
+		t.saveProgress()
+		t.next = i
+
+		// 等待命令执行完成，等待期间可以被中断
+		if sleepContext(ctx, step.wait()) != nil {
+			t.next = i + 1
+			return t.rollback(ErrInterrupted)
+		}
+	}
+
+	if !changed {
+		logger.Info("所有步骤均已是目标状态，无需修改")
+	}
+	return nil
+}
+
+// stepNeeded 判断步骤是否需要执行
+func stepNeeded(ctx context.Context, probe commandProber, step shellStep, changed bool) bool {
+	if step.onlyIfChanged && changed {
+		return true
+	}
+	if step.check == "" || probe == nil {
+		return !step.onlyIfChanged
+	}
+
+	output, err := probe(ctx, step.check)
+	if err != nil {
+		// 无法确认当前状态时按需要执行处理
+		logger.Warn("%s的前置检查失败: %v，将直接执行", step.name, err)
+		return true
+	}
+	return strings.TrimSpace(output) != step.expect
+}
+
+// RollbackError 步骤失败或被中断后执行了回滚
+type RollbackError struct {
+	Cause  error // 触发回滚的原因
+	Failed int   // 回滚失败的步骤数
+}
+
+func (e *RollbackError) Error() string {
+	if e.Failed > 0 {
+		return fmt.Sprintf("%v (回滚时有 %d 个步骤失败，路由器可能处于部分修改的状态)", e.Cause, e.Failed)
+	}
+	return fmt.Sprintf("%v (已回滚)", e.Cause)
+}
+
+func (e *RollbackError) Unwrap() error {
+	return e.Cause
+}
+
+// rollback 按相反顺序执行已执行步骤的撤销命令，最后执行一次 commit，返回原始错误并附带回滚结果
+// 撤销成功的步骤会从进度中移除，回滚失败时保存的进度仍然与路由器的实际状态一致
+// 回滚通常发生在ctx已被取消之后，因此使用独立的ctx，并限制总时长，再次按Ctrl-C会直接退出程序
+func (t *transaction) rollback(cause error) error {
+	logger.Warn("%v，开始回滚已执行的 %d 个步骤...", cause, len(t.executed))
+
+	ctx, cancel := context.WithTimeout(context.Background(), rollbackTimeout)
+	defer cancel()
+
+	failed, undone := 0, 0
+	for j := len(t.executed) - 1; j >= 0; j-- {
+		i := t.executed[j]
+		undo := t.undos[i]
+		if undo != "" {
+			logger.Info("回滚: %s...", t.steps[i].name)
+			if err := t.exec(ctx, undo); err != nil {
+				logger.Error("回滚 %s 失败: %v", t.steps[i].name, err)
+				t.record(i, StepRollbackFailed, err)
+				failed++
+				continue
+			}
+			undone++
+			sleepContext(ctx, t.steps[i].wait())
+		}
+		t.record(i, StepRolledBack, nil)
+
+		// 已撤销的步骤需要在继续执行时重新执行
+		t.executed = append(t.executed[:j], t.executed[j+1:]...)
+		if i < t.next {
+			t.next = i
+		}
+		t.saveProgress()
+	}
+
+	// 撤销命令只修改了内存中的值，需要提交后才能在重启后保持
+	if t.commit != "" && undone > 0 {
+		logger.Info("回滚: 提交恢复的配置...")
+		if err := t.exec(ctx, t.commit); err != nil {
+			logger.Error("提交恢复的配置失败: %v", err)
+			failed++
+		}
+	}
+
+	if failed == 0 {
+		logger.Info("回滚完成，路由器已恢复到操作前的状态")
+	}
+	return &RollbackError{Cause: cause, Failed: failed}
+}
+
+// wait 步骤执行后的等待时间
+func (s shellStep) wait() time.Duration {
+	if s.delay == 0 {
+		return stepDelay
+	}
+	return s.delay
+}
+
+// sleepContext 等待指定时间，ctx被取消时提前返回
+func sleepContext(ctx context.Context, d time.Duration) error {
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-timer.C:
+		return nil
+	}
+}
+
+// renderUndo 使用操作前记录的配置值渲染撤销命令
+func renderUndo(step shellStep, prior map[string]string) (string, error) {
+	if step.undo == "" {
+		return "", nil
+	}
+
+	tmpl, err := template.New(step.name).Option("missingkey=error").Parse(step.undo)
+	if err != nil {
+		return "", fmt.Errorf("解析 %s 的撤销命令失败: %v", step.name, err)
+	}
+
+	var buf bytes.Buffer
+	if err := tmpl.Execute(&buf, prior); err != nil {
+		return "", fmt.Errorf("无法生成 %s 的撤销命令: %v", step.name, err)
+	}
+	return buf.String(), nil
+}
+
+// containsInt 判断整数列表中是否包含指定值
+func containsInt(list []int, value int) bool {
+	for _, v := range list {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
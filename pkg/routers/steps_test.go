@@ -0,0 +1,140 @@
+package routers
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"testing"
+	"time"
+)
+
+// fakeRouter 记录执行过的命令，对指定命令返回错误
+type fakeRouter struct {
+	commands []string
+	failOn   map[string]bool
+}
+
+func (f *fakeRouter) exec(ctx context.Context, command string) error {
+	f.commands = append(f.commands, command)
+	if f.failOn[command] {
+		return errors.New("执行失败")
+	}
+	return nil
+}
+
+// nvramSteps 与内置配方相同结构的启用步骤，等待时间缩短以加快测试
+func nvramSteps() []shellStep {
+	delay := time.Millisecond
+	return []shellStep{
+		{name: "启用SSH配置", command: "nvram set ssh_en=1", undo: "nvram set ssh_en={{.ssh_en}}", delay: delay},
+		{name: "启用Telnet配置", command: "nvram set telnet_en=1", undo: "nvram set telnet_en={{.telnet_en}}", delay: delay},
+		{name: "提交NVRAM更改", command: "nvram commit", onlyIfChanged: true, delay: delay},
+		{name: "重启Dropbear服务", command: "/etc/init.d/dropbear restart", undo: "/etc/init.d/dropbear restart", onlyIfChanged: true, delay: delay},
+	}
+}
+
+func TestRollbackCommitsAfterAllUndos(t *testing.T) {
+	router := &fakeRouter{failOn: map[string]bool{"/etc/init.d/dropbear restart": true}}
+	prior := map[string]string{"ssh_en": "0", "telnet_en": "0"}
+
+	tx, err := newTransaction(router.exec, nil, nvramSteps(), prior, "nvram commit")
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = tx.run(context.Background())
+
+	var rollbackErr *RollbackError
+	if !errors.As(err, &rollbackErr) {
+		t.Fatalf("run() = %v，期望 RollbackError", err)
+	}
+	if rollbackErr.Failed != 1 {
+		t.Errorf("Failed = %d，期望 1 (失败步骤的撤销命令同样失败)", rollbackErr.Failed)
+	}
+
+	// 撤销命令按相反顺序执行，提交在所有撤销命令之后
+	want := []string{
+		"nvram set ssh_en=1",
+		"nvram set telnet_en=1",
+		"nvram commit",
+		"/etc/init.d/dropbear restart",
+		"/etc/init.d/dropbear restart",
+		"nvram set telnet_en=0",
+		"nvram set ssh_en=0",
+		"nvram commit",
+	}
+	if !reflect.DeepEqual(router.commands, want) {
+		t.Errorf("执行的命令:\n%q\n期望:\n%q", router.commands, want)
+	}
+}
+
+func TestRollbackWithoutUndoSkipsCommit(t *testing.T) {
+	router := &fakeRouter{failOn: map[string]bool{"nvram set ssh_en=1": true}}
+	steps := []shellStep{{name: "启用SSH配置", command: "nvram set ssh_en=1", delay: time.Millisecond}}
+
+	tx, err := newTransaction(router.exec, nil, steps, nil, "nvram commit")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := tx.run(context.Background()); err == nil {
+		t.Fatal("run() 成功，期望失败")
+	}
+
+	want := []string{"nvram set ssh_en=1"}
+	if !reflect.DeepEqual(router.commands, want) {
+		t.Errorf("执行的命令 %q，期望 %q", router.commands, want)
+	}
+}
+
+func TestRollbackResults(t *testing.T) {
+	router := &fakeRouter{failOn: map[string]bool{"nvram commit": true}}
+	prior := map[string]string{"ssh_en": "0", "telnet_en": "1"}
+
+	tx, err := newTransaction(router.exec, nil, nvramSteps()[:3], prior, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := tx.run(context.Background()); err == nil {
+		t.Fatal("run() 成功，期望失败")
+	}
+
+	want := []StepResult{
+		{Name: "启用SSH配置", Status: StepRolledBack},
+		{Name: "启用Telnet配置", Status: StepRolledBack},
+		{Name: "提交NVRAM更改", Status: StepRolledBack, Error: "执行失败"},
+	}
+	if got := tx.Results(); !reflect.DeepEqual(got, want) {
+		t.Errorf("Results() = %+v，期望 %+v", got, want)
+	}
+	if len(tx.executed) != 0 || tx.next != 0 {
+		t.Errorf("回滚后进度为 next=%d executed=%v，期望全部撤销", tx.next, tx.executed)
+	}
+}
+
+func TestRunSkipsStepsAlreadyInTargetState(t *testing.T) {
+	router := &fakeRouter{}
+	probe := func(ctx context.Context, command string) (string, error) {
+		return "1", nil
+	}
+	steps := nvramSteps()
+	for i := range steps {
+		steps[i].check, steps[i].expect = "nvram get", "1"
+	}
+
+	tx, err := newTransaction(router.exec, probe, steps, map[string]string{"ssh_en": "1", "telnet_en": "1"}, "nvram commit")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := tx.run(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	if len(router.commands) != 0 {
+		t.Errorf("执行了命令 %q，期望全部跳过", router.commands)
+	}
+}
+
+func TestNewTransactionRejectsMissingPrior(t *testing.T) {
+	_, err := newTransaction((&fakeRouter{}).exec, nil, nvramSteps(), map[string]string{"ssh_en": "0"}, "")
+	if err == nil {
+		t.Fatal("缺少 telnet_en 时 newTransaction 应失败")
+	}
+}
@@ -0,0 +1,110 @@
+package state
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/bamzest/xiaomi-router-shell-enabler/pkg/logger"
+)
+
+// 状态文件所在的目录名
+const appDirName = "xiaomi-router-shell-enabler"
+
+// HostState 每台路由器在本地保存的状态
+type HostState struct {
+	Host      string            `json:"host"`
+	Model     string            `json:"model"`
+	Operation string            `json:"operation,omitempty"` // 正在进行的操作，如 enable/disable
+	Services  []string          `json:"services,omitempty"`  // 操作涉及的服务
+	Prior     map[string]string `json:"prior,omitempty"`     // 操作前记录的配置值，用于回滚
+	Steps     []string          `json:"steps,omitempty"`     // 操作的全部步骤名称，用于继续执行时核对
+	NextStep  int               `json:"next_step"`           // 下一个要执行的步骤
+	Executed  []int             `json:"executed,omitempty"`  // 已实际执行的步骤
+	UpdatedAt time.Time         `json:"updated_at"`
+}
+
+// Dir 返回状态文件目录
+func Dir() (string, error) {
+	configDir, err := os.UserConfigDir()
+	if err != nil {
+		return "", fmt.Errorf("无法获取配置目录: %v", err)
+	}
+	return filepath.Join(configDir, appDirName, "state"), nil
+}
+
+// path 返回指定主机的状态文件路径
+func path(host string) (string, error) {
+	dir, err := Dir()
+	if err != nil {
+		return "", err
+	}
+	// 主机地址中可能包含端口或IPv6的冒号
+	name := strings.NewReplacer(":", "_", "/", "_", "\\", "_").Replace(host)
+	return filepath.Join(dir, name+".json"), nil
+}
+
+// Load 读取指定主机的状态，文件不存在时返回空状态
+func Load(host string) (*HostState, error) {
+	p, err := path(host)
+	if err != nil {
+		return nil, err
+	}
+
+	content, err := os.ReadFile(p)
+	if os.IsNotExist(err) {
+		return &HostState{Host: host}, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("读取状态文件失败: %v", err)
+	}
+
+	var s HostState
+	if err := json.Unmarshal(content, &s); err != nil {
+		return nil, fmt.Errorf("解析状态文件 %s 失败: %v", p, err)
+	}
+	s.Host = host
+	return &s, nil
+}
+
+// Save 保存状态
+func (s *HostState) Save() error {
+	p, err := path(s.Host)
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(p), 0700); err != nil {
+		return fmt.Errorf("创建状态目录失败: %v", err)
+	}
+
+	s.UpdatedAt = time.Now()
+	content, err := json.MarshalIndent(s, "", "  ")
+	if err != nil {
+		return fmt.Errorf("序列化状态失败: %v", err)
+	}
+	if err := os.WriteFile(p, content, 0600); err != nil {
+		return fmt.Errorf("写入状态文件失败: %v", err)
+	}
+
+	logger.Debug("已保存状态: %s", p)
+	return nil
+}
+
+// InProgress 是否有未完成的操作
+func (s *HostState) InProgress() bool {
+	return s.Operation != ""
+}
+
+// Clear 清除正在进行的操作记录
+func (s *HostState) Clear() error {
+	s.Operation = ""
+	s.Services = nil
+	s.Prior = nil
+	s.Steps = nil
+	s.NextStep = 0
+	s.Executed = nil
+	return s.Save()
+}
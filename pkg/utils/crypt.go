@@ -0,0 +1,112 @@
+package utils
+
+import (
+	"crypto/md5"
+	"crypto/rand"
+	"fmt"
+	"strings"
+)
+
+// crypt(3) 使用的base64字母表
+const cryptAlphabet = "./0123456789ABCDEFGHIJKLMNOPQRSTUVWXYZabcdefghijklmnopqrstuvwxyz"
+
+// md5crypt 的前缀
+const md5CryptMagic = "$1$"
+
+// MD5Crypt 生成 /etc/shadow 可用的 $1$ 格式密码哈希
+// 路由器上的 busybox/uClibc 均支持该格式，salt 为空时随机生成
+func MD5Crypt(password, salt string) (string, error) {
+	if salt == "" {
+		var err error
+		salt, err = randomCryptSalt(8)
+		if err != nil {
+			return "", err
+		}
+	}
+	if len(salt) > 8 {
+		salt = salt[:8]
+	}
+
+	pw := []byte(password)
+	s := []byte(salt)
+
+	alt := md5.New()
+	alt.Write(pw)
+	alt.Write(s)
+	alt.Write(pw)
+	altSum := alt.Sum(nil)
+
+	ctx := md5.New()
+	ctx.Write(pw)
+	ctx.Write([]byte(md5CryptMagic))
+	ctx.Write(s)
+	for n := len(pw); n > 0; n -= 16 {
+		if n > 16 {
+			ctx.Write(altSum)
+		} else {
+			ctx.Write(altSum[:n])
+		}
+	}
+	for n := len(pw); n != 0; n >>= 1 {
+		if n&1 != 0 {
+			ctx.Write([]byte{0})
+		} else {
+			ctx.Write(pw[:1])
+		}
+	}
+	final := ctx.Sum(nil)
+
+	// 1000轮迭代，用于拖慢暴力破解
+	for i := 0; i < 1000; i++ {
+		round := md5.New()
+		if i&1 != 0 {
+			round.Write(pw)
+		} else {
+			round.Write(final)
+		}
+		if i%3 != 0 {
+			round.Write(s)
+		}
+		if i%7 != 0 {
+			round.Write(pw)
+		}
+		if i&1 != 0 {
+			round.Write(final)
+		} else {
+			round.Write(pw)
+		}
+		final = round.Sum(nil)
+	}
+
+	var b strings.Builder
+	b.WriteString(md5CryptMagic)
+	b.WriteString(salt)
+	b.WriteByte('$')
+	for _, g := range [][3]int{{0, 6, 12}, {1, 7, 13}, {2, 8, 14}, {3, 9, 15}, {4, 10, 5}} {
+		v := uint(final[g[0]])<<16 | uint(final[g[1]])<<8 | uint(final[g[2]])
+		writeCrypt64(&b, v, 4)
+	}
+	writeCrypt64(&b, uint(final[11]), 2)
+
+	return b.String(), nil
+}
+
+// writeCrypt64 按crypt(3)的方式输出n个base64字符
+func writeCrypt64(b *strings.Builder, v uint, n int) {
+	for ; n > 0; n-- {
+		b.WriteByte(cryptAlphabet[v&0x3f])
+		v >>= 6
+	}
+}
+
+// randomCryptSalt 生成随机salt
+func randomCryptSalt(n int) (string, error) {
+	buf := make([]byte, n)
+	if _, err := rand.Read(buf); err != nil {
+		return "", fmt.Errorf("生成随机salt失败: %v", err)
+	}
+	for i := range buf {
+		buf[i] = cryptAlphabet[int(buf[i])%len(cryptAlphabet)]
+	}
+	return string(buf), nil
+}
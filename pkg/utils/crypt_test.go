@@ -0,0 +1,54 @@
+package utils
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestMD5Crypt(t *testing.T) {
+	// 期望值由 openssl passwd -1 -salt <salt> <password> 生成
+	for _, tc := range []struct {
+		password, salt, want string
+	}{
+		{"password", "saltsalt", "$1$saltsalt$qjXMvbEw8oaL.CzflDtaK/"},
+		{"password", "abc", "$1$abc$BXBqpb9BZcZhXLgbee.0s/"},
+		{"", "saltsalt", "$1$saltsalt$5Jhcit4zN9UlGiA0txPkO0"},
+		{"", "abc", "$1$abc$Or2rbeUYTvt12aiVzMuS/."},
+		{"a", "./Az09xy", "$1$./Az09xy$f.ORzXJ.VaAx5tEnWAj4F/"},
+		// 密码长于16字节时 altSum 要写入多次
+		{"0123456789abcdef0123456789abcdefXYZ", "saltsalt", "$1$saltsalt$zX8ezHTJZcQ5XCW7l.q8/."},
+		{"密码123", "saltsalt", "$1$saltsalt$E1WhlfCipsTDHxLvyqG0S/"},
+		// salt 超过8个字符时截断
+		{"password", "saltsaltEXTRA", "$1$saltsalt$qjXMvbEw8oaL.CzflDtaK/"},
+	} {
+		got, err := MD5Crypt(tc.password, tc.salt)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got != tc.want {
+			t.Errorf("MD5Crypt(%q, %q) = %s，期望 %s", tc.password, tc.salt, got, tc.want)
+		}
+	}
+}
+
+func TestMD5CryptRandomSalt(t *testing.T) {
+	a, err := MD5Crypt("password", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	b, err := MD5Crypt("password", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if a == b {
+		t.Error("两次随机生成的salt相同")
+	}
+	// 用生成的salt重新计算应得到相同的结果
+	salt := strings.Split(a, "$")[2]
+	if len(salt) != 8 || strings.Trim(salt, cryptAlphabet) != "" {
+		t.Fatalf("随机salt %q 无效", salt)
+	}
+	if again, _ := MD5Crypt("password", salt); again != a {
+		t.Errorf("MD5Crypt(%q) = %s，期望 %s", salt, again, a)
+	}
+}
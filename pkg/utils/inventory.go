@@ -0,0 +1,196 @@
+package utils
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+)
+
+// 自动识别的序列号列和型号列的列名
+var (
+	snColumnNames    = []string{"sn", "serial", "serial_number", "serialnumber", "序列号"}
+	modelColumnNames = []string{"model", "型号"}
+)
+
+// 输出时追加的列
+var inventoryOutputColumns = []string{"ssh_password", "algorithm", "note"}
+
+// InventoryRow 清单中的一行及其计算结果
+type InventoryRow struct {
+	Line       int // 在文件中的行号
+	Fields     []string
+	SN         string
+	Model      string
+	Candidates []PasswordCandidate
+	Err        error // 序列号无效的原因
+}
+
+// Inventory 从CSV读取的序列号清单
+type Inventory struct {
+	Header []string // 没有表头时为空
+	Rows   []InventoryRow
+}
+
+// ReadInventory 从CSV读取序列号清单
+// column 为序列号所在列的列名或从1开始的列号，为空时按列名自动识别；没有表头时使用第一列
+func ReadInventory(r io.Reader, column string) (*Inventory, error) {
+	reader := csv.NewReader(r)
+	reader.FieldsPerRecord = -1
+	reader.TrimLeadingSpace = true
+	records, err := reader.ReadAll()
+	if err != nil {
+		return nil, fmt.Errorf("读取CSV失败: %v", err)
+	}
+	if len(records) == 0 {
+		return nil, fmt.Errorf("CSV文件为空")
+	}
+
+	inv := &Inventory{}
+	first := records[0]
+	// 第一行包含序列号列名或型号列名时视为表头
+	hasHeader := findColumn(first, snColumnNames...) >= 0 || findColumn(first, modelColumnNames...) >= 0
+
+	var snIndex int
+	if n, err := strconv.Atoi(column); err == nil {
+		if n < 1 {
+			return nil, fmt.Errorf("列号必须从1开始: %s", column)
+		}
+		snIndex = n - 1
+	} else if column != "" {
+		if snIndex = findColumn(first, column); snIndex < 0 {
+			return nil, fmt.Errorf("表头中没有列 %s", column)
+		}
+		hasHeader = true
+	} else if hasHeader {
+		if snIndex = findColumn(first, snColumnNames...); snIndex < 0 {
+			return nil, fmt.Errorf("无法识别序列号所在的列，请指定列名或列号")
+		}
+	}
+
+	modelIndex := -1
+	if hasHeader {
+		inv.Header = first
+		modelIndex = findColumn(first, modelColumnNames...)
+		records = records[1:]
+	}
+
+	for i, fields := range records {
+		row := InventoryRow{Line: i + 1, Fields: fields}
+		if hasHeader {
+			row.Line++
+		}
+		if snIndex < len(fields) {
+			row.SN = strings.TrimSpace(fields[snIndex])
+		}
+		if modelIndex >= 0 && modelIndex < len(fields) {
+			row.Model = strings.TrimSpace(fields[modelIndex])
+		}
+		inv.Rows = append(inv.Rows, row)
+	}
+	return inv, nil
+}
+
+// Calculate 逐行选择算法计算SSH密码，序列号无效的行记录原因后继续
+// resolveModel 用于把型号列的值转换为算法使用的型号ID，可以为空
+func (inv *Inventory) Calculate(resolveModel func(string) string) (invalid int) {
+	for i := range inv.Rows {
+		row := &inv.Rows[i]
+		model := row.Model
+		if model != "" && resolveModel != nil {
+			model = resolveModel(model)
+		}
+		row.Candidates, row.Err = CandidatePasswords(row.SN, model)
+		if row.Err != nil {
+			invalid++
+		}
+	}
+	return invalid
+}
+
+// WriteCSV 输出原有的列并追加密码、算法和说明，有多个候选时以 '|' 分隔
+func (inv *Inventory) WriteCSV(w io.Writer) error {
+	writer := csv.NewWriter(w)
+	if inv.Header != nil {
+		if err := writer.Write(append(append([]string{}, inv.Header...), inventoryOutputColumns...)); err != nil {
+			return err
+		}
+	}
+
+	width := len(inv.Header)
+	for _, row := range inv.Rows {
+		if len(row.Fields) > width {
+			width = len(row.Fields)
+		}
+	}
+
+	for _, row := range inv.Rows {
+		record := make([]string, width, width+len(inventoryOutputColumns))
+		copy(record, row.Fields)
+
+		var passwords, algorithms []string
+		for _, c := range row.Candidates {
+			passwords = append(passwords, c.Password)
+			algorithms = append(algorithms, c.Algorithm.Name)
+		}
+		note := ""
+		switch {
+		case row.Err != nil:
+			note = "无效: " + row.Err.Error()
+		case len(row.Candidates) > 1:
+			note = "序列号符合多种算法，请依次尝试"
+		}
+		record = append(record, strings.Join(passwords, "|"), strings.Join(algorithms, "|"), note)
+		if err := writer.Write(record); err != nil {
+			return err
+		}
+	}
+	writer.Flush()
+	return writer.Error()
+}
+
+// InventoryResult 一行的计算结果，用于JSON输出
+type InventoryResult struct {
+	Line       int              `json:"line"`
+	SN         string           `json:"sn"`
+	Model      string           `json:"model,omitempty"`
+	Valid      bool             `json:"valid"`
+	Error      string           `json:"error,omitempty"`
+	Candidates []PasswordResult `json:"candidates,omitempty"`
+}
+
+// Results 每一行的计算结果
+func (inv *Inventory) Results() []InventoryResult {
+	results := make([]InventoryResult, 0, len(inv.Rows))
+	for _, row := range inv.Rows {
+		result := InventoryResult{Line: row.Line, SN: row.SN, Model: row.Model, Valid: row.Err == nil}
+		if row.Err != nil {
+			result.Error = row.Err.Error()
+		}
+		result.Candidates = PasswordResults(row.Candidates)
+		results = append(results, result)
+	}
+	return results
+}
+
+// WriteJSON 以JSON数组输出每一行的结果
+func (inv *Inventory) WriteJSON(w io.Writer) error {
+	encoder := json.NewEncoder(w)
+	encoder.SetIndent("", "  ")
+	return encoder.Encode(inv.Results())
+}
+
+// findColumn 按列名查找列，忽略大小写和空格
+func findColumn(header []string, names ...string) int {
+	for i, field := range header {
+		field = strings.ToLower(strings.TrimSpace(field))
+		for _, name := range names {
+			if field == strings.ToLower(name) {
+				return i
+			}
+		}
+	}
+	return -1
+}
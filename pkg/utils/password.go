@@ -3,47 +3,208 @@ package utils
 import (
 	"crypto/md5"
 	"fmt"
+	"regexp"
 	"strings"
 
 	"github.com/bamzest/xiaomi-router-shell-enabler/pkg/logger"
 )
 
-// 路由器固件解包后，从 /bin/mkxqimage 中提取的盐
-var salt = map[string]string{
-	"r1d":    "A2E371B0-B34B-48A5-8C40-A7133F3B5D88",
-	"others": "d44fb0960aa0-a5e6-4a30-250f-6d2df50a",
+// PasswordAlgorithm 由序列号计算SSH密码的一种算法
+// 密码为 md5(SN + 盐) 的前 8 个字符，不同代的固件使用不同的盐，盐从固件的 /bin/mkxqimage 中提取
+// 新型号的盐可以用 -extract-salt 从固件中查找，Salt 和 Swap 按其输出填写
+type PasswordAlgorithm struct {
+	Name        string
+	Description string
+	Pattern     *regexp.Regexp // 适用的序列号格式
+	Format      string         // 序列号格式的说明，用于错误提示
+	Models      []string       // 已知使用该算法的型号，为空表示没有限定
+	Salt        string
+	Swap        bool // 盐要按 '-' 分段反转后才能使用
+}
+
+// Calculate 计算SSH密码
+func (a *PasswordAlgorithm) Calculate(sn string) string {
+	saltValue := a.Salt
+	if a.Swap {
+		saltValue = swapSalt(saltValue)
+	}
+	logger.Debug("使用算法 %s，盐值: %s", a.Name, saltValue)
+
+	md5sum := md5.Sum([]byte(sn + saltValue))
+	return fmt.Sprintf("%x", md5sum)[:8]
+}
+
+// Matches 序列号是否符合算法适用的格式
+func (a *PasswordAlgorithm) Matches(sn string) bool {
+	return a.Pattern.MatchString(sn)
+}
+
+// HintsModel 型号是否在算法已知适用的型号中
+func (a *PasswordAlgorithm) HintsModel(model string) bool {
+	model = normalizeModelHint(model)
+	for _, m := range a.Models {
+		if normalizeModelHint(m) == model {
+			return true
+		}
+	}
+	return false
+}
+
+// 已注册的密码算法，按注册顺序匹配
+var passwordAlgorithms []*PasswordAlgorithm
+
+// RegisterPasswordAlgorithm 注册密码算法，名称重复属于程序错误
+func RegisterPasswordAlgorithm(a *PasswordAlgorithm) {
+	for _, existing := range passwordAlgorithms {
+		if existing.Name == a.Name {
+			panic(fmt.Sprintf("utils: 密码算法 %q 重复注册", a.Name))
+		}
+	}
+	passwordAlgorithms = append(passwordAlgorithms, a)
+}
+
+// PasswordAlgorithms 返回所有已注册的密码算法
+func PasswordAlgorithms() []*PasswordAlgorithm {
+	return passwordAlgorithms
+}
+
+func init() {
+	RegisterPasswordAlgorithm(&PasswordAlgorithm{
+		Name:        "r1d",
+		Description: "R1D 的序列号不含 '/'，使用原始的盐",
+		Pattern:     regexp.MustCompile(`^[0-9A-Za-z]{6,20}$`),
+		Format:      "6-20 位字母和数字，不含 '/'",
+		Models:      []string{"r1d"},
+		Salt:        "A2E371B0-B34B-48A5-8C40-A7133F3B5D88",
+	})
+	RegisterPasswordAlgorithm(&PasswordAlgorithm{
+		Name:        "others",
+		Description: "R1D 以外的型号，序列号为 NNNNN/XXXXXXXX 格式，使用反转后的盐",
+		Pattern:     regexp.MustCompile(`^[0-9]{4,6}/[0-9A-Za-z]{6,12}$`),
+		Format:      "NNNNN/XXXXXXXX，如 39668/A1ZZ38217",
+		Models:      []string{"redmi_ax5400pro", "xiaomi_ax3600", "xiaomi_ax6000"},
+		Salt:        "d44fb0960aa0-a5e6-4a30-250f-6d2df50a",
+		Swap:        true,
+	})
+}
+
+// PasswordCandidate 一个可能的SSH密码及其依据
+type PasswordCandidate struct {
+	Algorithm *PasswordAlgorithm
+	Password  string
+	Reason    string
+}
+
+// PasswordResult 候选密码的JSON形式
+type PasswordResult struct {
+	Password  string `json:"password"`
+	Algorithm string `json:"algorithm"`
+	Reason    string `json:"reason"`
+}
+
+// PasswordResults 将候选密码转换为JSON形式
+func PasswordResults(candidates []PasswordCandidate) []PasswordResult {
+	var results []PasswordResult
+	for _, c := range candidates {
+		results = append(results, PasswordResult{Password: c.Password, Algorithm: c.Algorithm.Name, Reason: c.Reason})
+	}
+	return results
+}
+
+// ModelMismatchError 序列号的格式与型号已知使用的算法不符
+// 通常是抄错了序列号或型号，无法确定该用哪种算法
+type ModelMismatchError struct {
+	SN    string
+	Model string
+}
+
+func (e *ModelMismatchError) Error() string {
+	return fmt.Sprintf("序列号 %s 不符合型号 %s 使用的算法的格式，请检查序列号和型号是否抄写有误", e.SN, e.Model)
+}
+
+// CandidatePasswords 计算序列号所有可能的SSH密码
+// 序列号不符合任何已知格式时返回错误；符合多种格式时返回所有候选，型号提示命中的排在前面
+// 型号提示与序列号格式矛盾时返回 ModelMismatchError，同时返回两种算法各自的候选供核对
+func CandidatePasswords(sn, model string) ([]PasswordCandidate, error) {
+	sn = strings.TrimSpace(sn)
+	if sn == "" {
+		return nil, fmt.Errorf("序列号为空")
+	}
+
+	var hinted, others []PasswordCandidate
+	for _, a := range passwordAlgorithms {
+		if !a.Matches(sn) {
+			continue
+		}
+		candidate := PasswordCandidate{Algorithm: a, Password: a.Calculate(sn), Reason: a.Description}
+		if model != "" && a.HintsModel(model) {
+			candidate.Reason += fmt.Sprintf("；型号 %s 已知使用该算法", model)
+			hinted = append(hinted, candidate)
+		} else {
+			if model != "" && len(a.Models) > 0 {
+				candidate.Reason += fmt.Sprintf("；型号 %s 不在已知使用该算法的型号中", model)
+			}
+			others = append(others, candidate)
+		}
+	}
+
+	if len(hinted)+len(others) == 0 {
+		return nil, fmt.Errorf("序列号 %s 不符合任何已知格式 (%s)，请检查是否抄写有误", sn, expectedFormats())
+	}
+	// 序列号只符合型号不使用的算法，不能替用户选择其中一种
+	if model != "" && len(hinted) == 0 {
+		for _, a := range passwordAlgorithms {
+			if a.Matches(sn) || !a.HintsModel(model) {
+				continue
+			}
+			hinted = append(hinted, PasswordCandidate{
+				Algorithm: a,
+				Password:  a.Calculate(sn),
+				Reason:    fmt.Sprintf("型号 %s 已知使用该算法，但序列号不符合其格式 (%s)", model, a.Format),
+			})
+		}
+		if len(hinted) > 0 {
+			return append(hinted, others...), &ModelMismatchError{SN: sn, Model: model}
+		}
+	}
+
+	candidates := append(hinted, others...)
+	logger.Debug("序列号 %s 有 %d 个候选密码", sn, len(candidates))
+	return candidates, nil
+}
+
+// ValidateSerialNumber 检查序列号是否符合已知格式
+func ValidateSerialNumber(sn string) error {
+	_, err := CandidatePasswords(sn, "")
+	return err
 }
 
 // CalculateSSHPassword 计算小米路由器SSH密码
-// 密码算法：原始 SN 拼接反转后的盐，做 md5 运算取前 8 个字符
+// 密码算法：原始 SN 拼接盐，做 md5 运算取前 8 个字符；有多个候选时返回第一个，序列号无效时返回空
 func CalculateSSHPassword(sn string) string {
-	if sn == "" {
-		logger.Warn("序列号为空，无法计算SSH密码")
+	candidates, err := CandidatePasswords(sn, "")
+	if err != nil {
+		logger.Warn("无法计算SSH密码: %v", err)
 		return ""
 	}
-	
-	logger.Debug("计算SSH密码，序列号: %s", sn)
-	saltValue := getSalt(sn)
-	logger.Debug("使用盐值: %s", saltValue)
-	
-	// 计算MD5
-	md5sum := md5.Sum([]byte(sn + saltValue))
-	password := fmt.Sprintf("%x", md5sum)[:8]
-	
-	logger.Debug("计算得到的密码: %s", password)
-	return password
+	if len(candidates) > 1 {
+		logger.Warn("序列号 %s 符合多种算法，使用 %s 计算", sn, candidates[0].Algorithm.Name)
+	}
+	return candidates[0].Password
 }
 
-// getSalt 根据序列号选择合适的盐值
-// SN 中不含 '/' 则为 r1d
-func getSalt(sn string) string {
-	if !strings.Contains(sn, "/") {
-		logger.Debug("检测到R1D路由器")
-		return salt["r1d"]
-	} else {
-		logger.Debug("检测到非R1D路由器")
-		return swapSalt(salt["others"])
+// expectedFormats 所有算法的序列号格式说明
+func expectedFormats() string {
+	formats := make([]string, 0, len(passwordAlgorithms))
+	for _, a := range passwordAlgorithms {
+		formats = append(formats, fmt.Sprintf("%s: %s", a.Name, a.Format))
 	}
+	return strings.Join(formats, "; ")
+}
+
+// normalizeModelHint 统一型号名称的写法，忽略大小写、空格、下划线和连字符
+func normalizeModelHint(model string) string {
+	return strings.NewReplacer(" ", "", "_", "", "-", "").Replace(strings.ToLower(strings.TrimSpace(model)))
 }
 
 // swapSalt 非R1D盐要反转后才能使用
@@ -53,4 +214,4 @@ func swapSalt(salt string) string {
 		parts[i], parts[j] = parts[j], parts[i]
 	}
 	return strings.Join(parts, "-")
-}
\ No newline at end of file
+}
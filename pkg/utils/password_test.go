@@ -0,0 +1,47 @@
+package utils
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestCandidatePasswords(t *testing.T) {
+	for _, tc := range []struct {
+		sn, model  string
+		algorithms []string
+		mismatch   bool
+	}{
+		{"39668/A1ZZ38217", "", []string{"others"}, false},
+		{"39668/A1ZZ38217", "redmi_ax5400pro", []string{"others"}, false},
+		{"39668A1ZZ38217", "", []string{"r1d"}, false},
+		{"39668A1ZZ38217", "r1d", []string{"r1d"}, false},
+		// 型号使用的算法不接受该序列号，两种算法的结果都要列出
+		{"39668A1ZZ38217", "redmi_ax5400pro", []string{"others", "r1d"}, true},
+		// 没有任何算法已知适用的型号不构成矛盾
+		{"39668A1ZZ38217", "unknown", []string{"r1d"}, false},
+	} {
+		candidates, err := CandidatePasswords(tc.sn, tc.model)
+		var mismatch *ModelMismatchError
+		if errors.As(err, &mismatch) != tc.mismatch || (err != nil && !tc.mismatch) {
+			t.Errorf("CandidatePasswords(%q, %q) 错误为 %v", tc.sn, tc.model, err)
+		}
+		var algorithms []string
+		for _, c := range candidates {
+			algorithms = append(algorithms, c.Algorithm.Name)
+		}
+		if len(algorithms) != len(tc.algorithms) {
+			t.Errorf("CandidatePasswords(%q, %q) 使用的算法为 %v，期望 %v", tc.sn, tc.model, algorithms, tc.algorithms)
+			continue
+		}
+		for i := range algorithms {
+			if algorithms[i] != tc.algorithms[i] {
+				t.Errorf("CandidatePasswords(%q, %q) 使用的算法为 %v，期望 %v", tc.sn, tc.model, algorithms, tc.algorithms)
+				break
+			}
+		}
+	}
+
+	if _, err := CandidatePasswords("39668/A1", "redmi_ax5400pro"); err == nil {
+		t.Error("不符合任何格式的序列号应返回错误")
+	}
+}
@@ -0,0 +1,190 @@
+package utils
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"golang.org/x/crypto/ssh"
+	"golang.org/x/crypto/ssh/agent"
+
+	"github.com/bamzest/xiaomi-router-shell-enabler/pkg/logger"
+)
+
+// SSH登录验证的超时时间
+const sshLoginTimeout = 10 * time.Second
+
+// ErrNoPrivateKey 本地没有公钥对应的私钥，无法验证公钥登录
+var ErrNoPrivateKey = errors.New("没有可用的私钥")
+
+// AuthorizedKey 待写入路由器的公钥
+type AuthorizedKey struct {
+	Path string        // 公钥文件路径
+	Line string        // 规范化后的 authorized_keys 行
+	Key  ssh.PublicKey // 解析后的公钥
+}
+
+// ExpandHome 展开路径开头的 ~
+func ExpandHome(path string) string {
+	if path != "~" && !strings.HasPrefix(path, "~/") {
+		return path
+	}
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return path
+	}
+	return filepath.Join(home, strings.TrimPrefix(path, "~"))
+}
+
+// ReadAuthorizedKeys 读取并校验公钥文件，每个文件可以包含多条公钥
+func ReadAuthorizedKeys(paths []string) ([]AuthorizedKey, error) {
+	var keys []AuthorizedKey
+	for _, p := range paths {
+		path := ExpandHome(p)
+		content, err := os.ReadFile(path)
+		if err != nil {
+			return nil, fmt.Errorf("读取公钥文件失败: %v", err)
+		}
+
+		rest := content
+		for len(bytes.TrimSpace(rest)) > 0 {
+			pub, comment, _, next, err := ssh.ParseAuthorizedKey(rest)
+			if err != nil {
+				return nil, fmt.Errorf("解析公钥文件 %s 失败: %v", path, err)
+			}
+			rest = next
+
+			keys = append(keys, AuthorizedKey{
+				Path: path,
+				Line: authorizedKeyLine(pub, comment),
+				Key:  pub,
+			})
+		}
+	}
+
+	if len(keys) == 0 {
+		return nil, fmt.Errorf("没有找到可用的公钥")
+	}
+	return keys, nil
+}
+
+// authorizedKeyLine 生成可以安全放入路由器命令中的公钥行
+// 命令经由JSON和shell传递，注释中的特殊字符会被丢弃
+func authorizedKeyLine(pub ssh.PublicKey, comment string) string {
+	line := strings.TrimSpace(string(ssh.MarshalAuthorizedKey(pub)))
+	if comment != "" && IsShellSafe(comment) {
+		line += " " + comment
+	}
+	return line
+}
+
+// IsShellSafe 判断字符串能否直接放入单引号中传给路由器执行
+func IsShellSafe(s string) bool {
+	return !strings.ContainsAny(s, "'\"\\`$\n\r")
+}
+
+// VerifySSHKeyLogin 使用公钥对应的私钥（或ssh-agent中的同一密钥）验证能否登录
+// 本地找不到私钥时返回的错误包含 ErrNoPrivateKey
+func VerifySSHKeyLogin(host string, key AuthorizedKey) error {
+	signer, closeSigner, err := findSigner(key)
+	if err != nil {
+		return err
+	}
+	defer closeSigner()
+	return verifySSHLogin(host, ssh.PublicKeys(signer))
+}
+
+// VerifySSHPasswordLogin 使用密码验证能否以root身份登录
+func VerifySSHPasswordLogin(host, password string) error {
+	return verifySSHLogin(host, ssh.Password(password))
+}
+
+// findSigner 查找公钥对应的私钥，私钥不可用时回退到ssh-agent
+// 使用ssh-agent时签名需要保持连接，返回的关闭函数在登录验证结束后调用
+func findSigner(key AuthorizedKey) (ssh.Signer, func(), error) {
+	privatePath := strings.TrimSuffix(key.Path, ".pub")
+	if privatePath != key.Path {
+		content, err := os.ReadFile(privatePath)
+		if err == nil {
+			signer, err := ssh.ParsePrivateKey(content)
+			if err == nil && bytes.Equal(signer.PublicKey().Marshal(), key.Key.Marshal()) {
+				return signer, func() {}, nil
+			}
+			var passErr *ssh.PassphraseMissingError
+			if err != nil && !errors.As(err, &passErr) {
+				logger.Debug("解析私钥 %s 失败: %v", privatePath, err)
+			}
+		}
+	}
+
+	sock := os.Getenv("SSH_AUTH_SOCK")
+	if sock == "" {
+		return nil, nil, fmt.Errorf("%w: 找不到 %s 对应的私钥，且未运行ssh-agent", ErrNoPrivateKey, key.Path)
+	}
+	conn, err := net.Dial("unix", sock)
+	if err != nil {
+		return nil, nil, fmt.Errorf("连接ssh-agent失败: %v", err)
+	}
+	closeConn := func() {
+		if err := conn.Close(); err != nil {
+			logger.Debug("关闭ssh-agent连接失败: %v", err)
+		}
+	}
+	signers, err := agent.NewClient(conn).Signers()
+	if err != nil {
+		closeConn()
+		return nil, nil, fmt.Errorf("读取ssh-agent密钥失败: %v", err)
+	}
+	for _, signer := range signers {
+		if bytes.Equal(signer.PublicKey().Marshal(), key.Key.Marshal()) {
+			return signer, closeConn, nil
+		}
+	}
+	closeConn()
+	return nil, nil, fmt.Errorf("%w: 私钥 %s 不可用，ssh-agent中也没有该密钥", ErrNoPrivateKey, privatePath)
+}
+
+// verifySSHLogin 以root身份登录并执行一条空命令
+func verifySSHLogin(host string, method ssh.AuthMethod) error {
+	config := &ssh.ClientConfig{
+		User: "root",
+		Auth: []ssh.AuthMethod{method},
+		// 刚启用的dropbear主机密钥无从校验
+		HostKeyCallback: ssh.InsecureIgnoreHostKey(),
+		// 小米固件中的dropbear较旧，需要允许ssh-rsa
+		HostKeyAlgorithms: []string{
+			ssh.KeyAlgoED25519,
+			ssh.KeyAlgoECDSA256,
+			ssh.KeyAlgoRSASHA256,
+			ssh.KeyAlgoRSA,
+		},
+		Timeout: sshLoginTimeout,
+	}
+
+	address := net.JoinHostPort(host, "22")
+	logger.Debug("尝试SSH登录: %s", address)
+
+	conn, err := ssh.Dial("tcp", address, config)
+	if err != nil {
+		return fmt.Errorf("SSH登录失败: %v", err)
+	}
+	defer conn.Close()
+
+	session, err := conn.NewSession()
+	if err != nil {
+		return fmt.Errorf("创建SSH会话失败: %v", err)
+	}
+	defer session.Close()
+
+	if err := session.Run("true"); err != nil {
+		return fmt.Errorf("SSH执行命令失败: %v", err)
+	}
+
+	logger.Debug("SSH登录成功: %s", address)
+	return nil
+}
@@ -0,0 +1,510 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"os/signal"
+	"strings"
+	"sync"
+	"syscall"
+
+	"github.com/bamzest/xiaomi-router-shell-enabler/pkg/auth"
+	"github.com/bamzest/xiaomi-router-shell-enabler/pkg/client"
+	"github.com/bamzest/xiaomi-router-shell-enabler/pkg/logger"
+	"github.com/bamzest/xiaomi-router-shell-enabler/pkg/routers"
+	"github.com/bamzest/xiaomi-router-shell-enabler/pkg/utils"
+)
+
+// routerOp 需要登录路由器的操作
+type routerOp struct {
+	name       string // 子命令名称，用于错误信息
+	capability routers.Capability
+	modifies   bool // 会修改路由器，执行前检查固件兼容性
+	run        func(s *session) bool
+}
+
+var (
+	opEnable    = &routerOp{name: "enable", capability: routers.CapEnableShell, modifies: true, run: runEnable}
+	opDisable   = &routerOp{name: "disable", capability: routers.CapDisableShell, modifies: true, run: runDisable}
+	opStatus    = &routerOp{name: "status", capability: routers.CapShellStatus, run: runStatus}
+	opExec      = &routerOp{name: "exec", capability: routers.CapExec, modifies: true, run: runExec}
+	opResume    = &routerOp{name: "resume", capability: routers.CapResume, modifies: true, run: runResume}
+	opPersist   = &routerOp{name: "persist", capability: routers.CapPersist, modifies: true, run: runPersist}
+	opUnpersist = &routerOp{name: "unpersist", capability: routers.CapPersist, modifies: true, run: runUnpersist}
+	opReboot    = &routerOp{name: "reboot", capability: routers.CapShellStatus, run: runReboot}
+	opMesh      = &routerOp{name: "mesh", capability: routers.CapMesh, run: runMeshOnly}
+)
+
+// session 已登录的路由器和本次操作的参数
+type session struct {
+	ctx      context.Context
+	opts     *options
+	op       *routerOp
+	desc     *routers.ModelDescriptor
+	client   client.RouterClient
+	password string
+	hashMode auth.HashMode // 路由器报告的登录哈希算法，读取不到路由器信息时为空
+	services []routers.Service
+
+	authorizedKeys  []utils.AuthorizedKey
+	newRootPassword string
+	serialNumber    string // 路由器报告或用户指定的序列号，保存配置时使用
+	meshRelay       bool   // 所用配方设置了 mesh_relay，可以在Mesh子节点上执行命令
+}
+
+// routerCommand 生成需要登录路由器的子命令，登录后执行操作，结束时删除临时任务并注销登录
+func routerCommand(op *routerOp) func(o *options, args []string) bool {
+	return func(o *options, args []string) bool {
+		if len(args) > 0 {
+			fmt.Fprintf(os.Stderr, "错误: 多余的参数: %s\n", strings.Join(args, " "))
+			return false
+		}
+		if !o.checkRouterFlags() {
+			return false
+		}
+
+		ctx, stop := signalContext()
+		defer stop()
+
+		s, ok := newSession(ctx, o, op)
+		if !ok {
+			return false
+		}
+		defer cleanupClient(s.client)
+		if !op.run(s) {
+			return false
+		}
+
+		// 操作成功后保存本次的路由器参数
+		if o.saveProfile != "" {
+			sn := s.serialNumber
+			if sn == "" {
+				sn = o.serialNumber
+			}
+			return saveProfile(o, o.model, sn)
+		}
+		return true
+	}
+}
+
+// runProbeCommand 探测命令通道，用于尚未支持的型号，不需要已知型号
+func runProbeCommand(o *options, args []string) bool {
+	if !o.checkRouterFlags() {
+		return false
+	}
+	ctx, stop := signalContext()
+	defer stop()
+	if !runProbe(ctx, o.host, o.routerPassword(), o.model) {
+		return false
+	}
+	if o.saveProfile != "" {
+		return saveProfile(o, o.model, o.serialNumber)
+	}
+	return true
+}
+
+// checkRouterFlags 检查连接路由器必需的参数，并规范主机地址
+func (o *options) checkRouterFlags() bool {
+	if o.host == "" || (o.password == "" && o.token == "") {
+		fmt.Fprintln(os.Stderr, "错误: 必须提供路由器IP地址 (-host) 和管理密码 (-password)")
+		return false
+	}
+
+	// 去掉协议前缀和尾部斜杠
+	o.host = strings.TrimPrefix(o.host, "http://")
+	o.host = strings.TrimPrefix(o.host, "https://")
+	o.host = strings.TrimSuffix(o.host, "/")
+	logger.Debug("使用主机地址: %s", o.host)
+	return true
+}
+
+// routerPassword 路由器管理密码，兼容已弃用的 -token 参数
+func (o *options) routerPassword() string {
+	if o.password == "" && o.token != "" {
+		logger.Warn("-token 参数已弃用，请使用 -password 参数")
+		return o.token
+	}
+	return o.password
+}
+
+// signalContext 收到 Ctrl-C 或 SIGTERM 时取消当前操作并回滚，再次按 Ctrl-C 强制退出
+// 使用单独的信号通道，正常结束时调用 stop 不会被当作中断
+func signalContext() (context.Context, context.CancelFunc) {
+	ctx, cancel := context.WithCancel(context.Background())
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, os.Interrupt, syscall.SIGTERM)
+
+	done := make(chan struct{})
+	go func() {
+		select {
+		case <-signals:
+			logger.Warn("收到中断信号，正在停止当前操作，再次按 Ctrl-C 强制退出")
+			// 恢复默认处理，再次收到信号时直接退出
+			signal.Stop(signals)
+			cancel()
+		case <-done:
+		}
+	}()
+
+	var once sync.Once
+	stop := func() {
+		once.Do(func() {
+			signal.Stop(signals)
+			close(done)
+			cancel()
+		})
+	}
+	return ctx, stop
+}
+
+// newSession 识别型号并检查是否支持所请求的操作，提前读取本地文件和密码，最后登录路由器
+func newSession(ctx context.Context, o *options, op *routerOp) (*session, bool) {
+	s := &session{ctx: ctx, opts: o, op: op, password: o.routerPassword()}
+
+	// 识别路由器型号，未指定 -model 时自动检测
+	out.Set("host", o.host)
+	modelDesc, routerInfo, err := resolveModel(ctx, o.host, o.model)
+	if routerInfo != nil {
+		out.Set("router", newRouterResult(routerInfo))
+	}
+	if err != nil {
+		logger.Error("%v", err)
+		out.Println("支持的型号: ", client.GetSupportedModels())
+		return nil, false
+	}
+	if routerInfo != nil {
+		logger.Info("路由器: %s", routerInfo.Description())
+		s.hashMode = routerInfo.HashMode()
+	}
+	s.desc = modelDesc
+	out.Set("model", newModelResult(modelDesc, false))
+
+	// 检查型号是否支持所请求的操作
+	for _, required := range []struct {
+		enabled    bool
+		capability routers.Capability
+		flagName   string
+	}{
+		{true, op.capability, op.name},
+		{len(o.authorizedKeyFiles) > 0, routers.CapAuthorizedKeys, "-authorized-key"},
+		{o.rootPassword != "" || o.rootPasswordPrompt, routers.CapRootPassword, "-root-password"},
+		{o.persist || o.unpersist, routers.CapPersist, "-persist/-unpersist"},
+		{o.recipeFile != "", routers.CapRecipe, "-recipe"},
+		{o.mesh, routers.CapMesh, "-mesh"},
+	} {
+		if required.enabled && !modelDesc.Supports(required.capability) {
+			logger.Error("%s 不支持 %s", modelDesc.DisplayName, required.flagName)
+			return nil, false
+		}
+	}
+	o.model = modelDesc.ID
+
+	// 修改路由器前对照兼容性记录检查固件版本，已知被修补的固件上执行只会走完所有步骤后失败
+	// 只检查状态的操作 (包括 status -mesh 和 mesh) 不受影响，enable -mesh 本身已属于修改操作
+	romVersion := ""
+	if routerInfo != nil {
+		romVersion = routerInfo.RomVersion
+	}
+	check := modelDesc.CheckFirmware(romVersion)
+	out.Set("firmware", check)
+	if op.modifies {
+		switch check.Status {
+		case routers.FirmwareWorking:
+			logger.Info("%s", check.Explain(modelDesc))
+		case routers.FirmwarePatched:
+			if !o.force {
+				logger.Error("%s", check.Explain(modelDesc))
+				logger.Error("此固件上的命令注入已被修补，操作不会成功，已停止，未做任何修改")
+				logger.Error("如果确认需要尝试，请使用 -force")
+				return nil, false
+			}
+			logger.Warn("%s，已使用 -force 继续", check.Explain(modelDesc))
+		default:
+			logger.Warn("%s，操作可能失败", check.Explain(modelDesc))
+		}
+	}
+
+	// 解析要操作的服务，exec 等命令没有 -services 参数
+	if o.services == "" {
+		o.services = defaultServices
+	}
+	if s.services, err = routers.ParseServices(o.services); err != nil {
+		logger.Error("%v", err)
+		return nil, false
+	}
+
+	// 提前读取并校验自定义配方
+	var recipe *routers.Recipe
+	if o.recipeFile != "" {
+		if recipe, err = routers.LoadRecipeFile(o.recipeFile); err != nil {
+			logger.Error("%v", err)
+			return nil, false
+		}
+	}
+
+	// 子节点的命令要经主路由转发，内置配方没有经过验证的转发命令，需要用户在自定义配方中提供
+	// 在修改主路由之前检查，避免 enable -mesh 只完成了主路由
+	if o.mesh || op == opMesh {
+		meshRecipe := recipe
+		if meshRecipe == nil {
+			if meshRecipe, err = routers.BuiltinRecipe(modelDesc.ID); err != nil {
+				logger.Error("%v", err)
+				return nil, false
+			}
+		}
+		s.meshRelay = meshRecipe.MeshRelay != ""
+		if o.mesh && op != opMesh && !s.meshRelay {
+			logger.Error("配方 %s 没有设置 mesh_relay，无法在Mesh子节点上执行命令", meshRecipe.Source)
+			logger.Error("内置配方不包含转发命令，请在自定义配方中设置在你的固件上测试过的 mesh_relay，并通过 -recipe 使用")
+			return nil, false
+		}
+	}
+
+	// 提前读取公钥文件，避免在路由器上执行到一半才发现文件有误
+	if len(o.authorizedKeyFiles) > 0 {
+		if op != opEnable {
+			logger.Warn("-authorized-key 仅在启用时生效")
+		} else if !containsSSH(s.services) {
+			logger.Warn("-authorized-key 仅在启用SSH时生效")
+		} else if s.authorizedKeys, err = utils.ReadAuthorizedKeys(o.authorizedKeyFiles); err != nil {
+			logger.Error("%v", err)
+			return nil, false
+		}
+	}
+
+	// 提前读取自定义root密码
+	if o.rootPasswordPrompt || o.rootPassword != "" {
+		if op != opEnable {
+			logger.Warn("-root-password 仅在启用时生效")
+		} else if o.rootPasswordPrompt {
+			if s.newRootPassword, err = promptNewPassword(); err != nil {
+				logger.Error("%v", err)
+				return nil, false
+			}
+		} else {
+			s.newRootPassword = o.rootPassword
+		}
+	}
+
+	logger.Debug("连接信息: 主机=%s, 型号=%s", o.host, o.model)
+
+	// 创建路由器客户端
+	s.client, err = client.NewRouterClient(ctx, o.host, s.password, o.model, s.hashMode)
+	if err != nil {
+		logger.Error("%v", err)
+		out.Println("支持的型号: ", client.GetSupportedModels())
+		return nil, false
+	}
+
+	if recipe != nil {
+		if err := s.client.UseRecipe(recipe); err != nil {
+			logger.Error("%v", err)
+			cleanupClient(s.client)
+			return nil, false
+		}
+	}
+	return s, true
+}
+
+// runStatus 检查所选服务的状态，显示登录凭据
+func runStatus(s *session) bool {
+	logger.Info("检查 %s 路由器的%s状态...", s.opts.model, routers.ServiceNames(s.services))
+	status, details, err := s.client.CheckShellStatus(s.ctx, s.services)
+	if err != nil {
+		logger.Error("检查状态失败: %v", err)
+		return false
+	}
+	out.Set("status", status)
+
+	// 按服务显示状态摘要
+	for _, service := range s.services {
+		if status.Ready(service) {
+			logger.Info("%s服务状态: 已启用并可访问", service.DisplayName())
+		} else {
+			logger.Warn("%s服务状态: %s", service.DisplayName(), status.Summary(service))
+		}
+	}
+
+	// 显示详细状态信息
+	out.Println("\n详细状态信息:")
+	out.Println(details)
+
+	// 显示由序列号计算的SSH密码和连接命令
+	s.serialNumber = resolveSerialNumber(s.ctx, s.client, s.opts.serialNumber)
+	printCredentials(s.client, s.services, s.opts.model, s.serialNumber)
+
+	// 检查Mesh子节点
+	if s.opts.mesh {
+		return runMesh(s.ctx, s.client, s.services, false)
+	}
+	return true
+}
+
+// runExec 执行自定义命令
+func runExec(s *session) bool {
+	logger.Info("执行自定义命令: %s", s.opts.execCommand)
+	out.Set("exec", s.opts.execCommand)
+	if err := s.client.ExecuteCustomCommand(s.ctx, s.opts.execCommand); err != nil {
+		logger.Error("执行命令失败: %v", err)
+		return false
+	}
+	logger.Info("命令执行完成")
+	return true
+}
+
+// runEnable 启用所选服务，按参数写入公钥、安装启动脚本、设置root密码、处理Mesh子节点并验证重启
+func runEnable(s *session) bool {
+	ctx, o := s.ctx, s.opts
+	serviceNames := routers.ServiceNames(s.services)
+
+	logger.Info("开始为 %s 路由器启用%s...", o.model, serviceNames)
+	result, err := s.client.EnableSSH(ctx, s.services)
+	setShellResult(result)
+	if err != nil {
+		logger.Error("启用%s失败: %v", serviceNames, err)
+		return false
+	}
+
+	// 写入SSH公钥并验证公钥登录
+	if len(s.authorizedKeys) > 0 {
+		if err := installAuthorizedKeys(ctx, s.client, o.host, s.authorizedKeys); err != nil {
+			logger.Error("%v", err)
+			return false
+		}
+	}
+
+	// 安装持久化启动脚本
+	if o.persist {
+		if err := s.client.InstallPersistence(ctx, s.services); err != nil {
+			logger.Error("%v", err)
+			return false
+		}
+	}
+
+	// 设置自定义root密码并验证密码登录
+	if s.newRootPassword != "" {
+		if err := s.client.SetRootPassword(ctx, s.newRootPassword); err != nil {
+			logger.Error("%v", err)
+			return false
+		}
+
+		// 密码是通过命令注入写入 /etc/shadow 的，无法得知是否生效，只有登录成功才能确认
+		credentials := &credentialsResult{Username: "root", CustomPassword: true}
+		label := "(自定义密码，未验证)"
+		if containsSSH(s.services) {
+			logger.Info("验证新root密码登录...")
+			if err := utils.VerifySSHPasswordLogin(o.host, s.newRootPassword); err != nil {
+				logger.Error("新root密码登录验证失败: %v", err)
+				logger.Error("root密码的当前状态未知: 可能仍是由序列号计算的密码，也可能已被部分修改，请通过Telnet或重新执行 enable -root-password 确认")
+				credentials.Error = "新root密码登录验证失败，密码状态未知"
+				out.Set("credentials", credentials)
+				return false
+			}
+			logger.Info("新root密码登录验证成功")
+			credentials.Verified = true
+			label = "(自定义密码)"
+		} else {
+			logger.Warn("没有启用SSH，无法验证新root密码是否生效")
+		}
+
+		out.Printf("\n登录凭据:\n")
+		out.Printf("  用户名: root\n")
+		out.Printf("  密码: %s\n", label)
+		printConnectionCommands(s.client, s.services, credentials)
+		out.Set("credentials", credentials)
+	} else {
+		// 显示由序列号计算的SSH密码和连接命令
+		s.serialNumber = resolveSerialNumber(ctx, s.client, o.serialNumber)
+		printCredentials(s.client, s.services, o.model, s.serialNumber)
+	}
+
+	// 在Mesh子节点上执行相同的启用步骤
+	if o.mesh && !runMesh(ctx, s.client, s.services, true) {
+		return false
+	}
+
+	// 重启并验证SSH是否保持启用
+	if o.verifyReboot {
+		return rebootAndVerify(ctx, s.client, o.host, s.password, o.model, s.hashMode, s.services, o.rebootTimeout)
+	}
+	return true
+}
+
+// runDisable 关闭所选服务，按参数移除启动脚本
+func runDisable(s *session) bool {
+	serviceNames := routers.ServiceNames(s.services)
+
+	logger.Info("开始为 %s 路由器关闭%s...", s.opts.model, serviceNames)
+	result, err := s.client.DisableSSH(s.ctx, s.services)
+	setShellResult(result)
+	if err != nil {
+		logger.Error("关闭%s失败: %v", serviceNames, err)
+		return false
+	}
+	logger.Info("%s关闭操作完成", serviceNames)
+
+	// 移除持久化启动脚本，否则重启后SSH会被重新启用
+	if !s.opts.unpersist {
+		logger.Warn("如果之前安装过启动脚本，重启后会重新启用SSH，可以使用 unpersist 移除")
+		return true
+	}
+	return runUnpersist(s)
+}
+
+// runResume 继续上次中断的操作
+func runResume(s *session) bool {
+	result, err := s.client.ResumeShell(s.ctx)
+	setShellResult(result)
+	if err != nil {
+		logger.Error("继续执行失败: %v", err)
+		return false
+	}
+	logger.Info("%s %s 操作已完成", routers.ServiceNames(result.Services), result.Operation)
+	return true
+}
+
+// setShellResult 记录启用/关闭操作的步骤和操作后的状态，文本模式下显示详细状态
+func setShellResult(result *routers.ShellResult) {
+	if result == nil {
+		return
+	}
+	out.Set("result", result)
+	if result.Details != "" {
+		out.Println("\n" + result.Details)
+	}
+}
+
+// runPersist 安装持久化启动脚本
+func runPersist(s *session) bool {
+	if err := s.client.InstallPersistence(s.ctx, s.services); err != nil {
+		logger.Error("%v", err)
+		return false
+	}
+	return true
+}
+
+// runUnpersist 移除持久化启动脚本
+func runUnpersist(s *session) bool {
+	if err := s.client.RemovePersistence(s.ctx); err != nil {
+		logger.Error("%v", err)
+		return false
+	}
+	return true
+}
+
+// runReboot 重启并验证所选服务是否保持启用
+func runReboot(s *session) bool {
+	return rebootAndVerify(s.ctx, s.client, s.opts.host, s.password, s.opts.model, s.hashMode, s.services, s.opts.rebootTimeout)
+}
+
+// runMeshOnly 列出Mesh节点并检查子节点状态，配方没有设置 mesh_relay 时只列出节点
+func runMeshOnly(s *session) bool {
+	if !s.meshRelay {
+		logger.Warn("配方没有设置 mesh_relay，只列出Mesh节点，不检查子节点状态")
+		logger.Warn("检查子节点状态请使用 status -mesh -recipe <配方>，配方中设置在你的固件上测试过的 mesh_relay")
+		_, _, ok := listMeshNodes(s.ctx, s.client)
+		return ok
+	}
+	return runMesh(s.ctx, s.client, s.services, false)
+}